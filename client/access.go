@@ -0,0 +1,39 @@
+package client
+
+import "terminal-chat/models"
+
+// handleAccessCommand asks the server to change who may join the active
+// room: /private, /public, /passphrase <phrase> and /invite [user]. An
+// invite without a user asks for a token to pass on.
+func (c *Client) handleAccessCommand(action, command string) {
+	room := c.activeRoom()
+	if room == "" {
+		c.showSystemMessage("You are not in any room. Use /join <room> to enter one.")
+		return
+	}
+	_, rest := splitCommand(command, 1)
+
+	msg := models.NewMessage(models.MessageTypeAccess, c.username, "", room)
+	msg.Action = action
+	switch action {
+	case models.AccessPassphrase:
+		if rest == "" {
+			c.showSystemMessage("Usage: /passphrase <phrase>, or /public to remove it")
+			return
+		}
+		msg.Key = rest
+	case models.AccessInvite:
+		msg.User = rest
+	}
+	c.send(msg)
+}
+
+// refusedEntry reports whether an error turned away a join, so the room is
+// forgotten instead of being rejoined on every reconnect
+func refusedEntry(code string) bool {
+	switch code {
+	case models.ErrCodeBanned, models.ErrCodeInviteOnly, models.ErrCodePassphrase:
+		return true
+	}
+	return false
+}
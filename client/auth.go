@@ -0,0 +1,112 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"terminal-chat/models"
+	"terminal-chat/utils"
+	"time"
+
+	"github.com/manifoldco/promptui"
+)
+
+// errUnknownAccount is returned by Login when the username is not registered
+var errUnknownAccount = errors.New("no account with that username")
+
+// Login exchanges a password for a session token
+func Login(server *Endpoint, username, password string) (*models.Session, error) {
+	resp, err := postCredentials(server, "/login", username, password)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var session models.Session
+		if err := json.NewDecoder(resp.Body).Decode(&session); err != nil {
+			return nil, err
+		}
+		return &session, nil
+	case http.StatusNotFound:
+		return nil, errUnknownAccount
+	}
+	return nil, apiError(resp)
+}
+
+// Register creates an account on the server
+func Register(server *Endpoint, username, password string) error {
+	resp, err := postCredentials(server, "/register", username, password)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return apiError(resp)
+	}
+	return nil
+}
+
+// authenticate logs in with the given password, offering to register the
+// username first when it has no account yet. An empty password joins as a guest.
+func authenticate(server *Endpoint, username, password string) (string, error) {
+	if password == "" {
+		return "", nil
+	}
+
+	session, err := Login(server, username, password)
+	if errors.Is(err, errUnknownAccount) {
+		confirm := promptui.Prompt{
+			Label:     fmt.Sprintf("No account named %s. Register it now", username),
+			IsConfirm: true,
+		}
+		if _, err := confirm.Run(); err != nil {
+			return "", fmt.Errorf("login cancelled")
+		}
+		if err := Register(server, username, password); err != nil {
+			return "", err
+		}
+		fmt.Println(utils.ColorSuccess("✓ Account created"))
+		session, err = Login(server, username, password)
+	}
+	if err != nil {
+		return "", err
+	}
+	return session.Token, nil
+}
+
+// relogin returns a login with the same password, for getting a new session
+// token when the server has forgotten the first
+func relogin(server *Endpoint, username, password string) func() (string, error) {
+	return func() (string, error) {
+		session, err := Login(server, username, password)
+		if err != nil {
+			return "", err
+		}
+		return session.Token, nil
+	}
+}
+
+// postCredentials POSTs a credentials body to an account endpoint
+func postCredentials(server *Endpoint, path, username, password string) (*http.Response, error) {
+	body, err := json.Marshal(models.Credentials{Username: username, Password: password})
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := server.HTTPClient(10 * time.Second)
+	return httpClient.Post(server.URL(path), "application/json", bytes.NewReader(body))
+}
+
+// apiError turns a failed API response into an error
+func apiError(resp *http.Response) error {
+	var apiErr models.APIError
+	if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || apiErr.Error == "" {
+		return fmt.Errorf("server returned %s", resp.Status)
+	}
+	return errors.New(apiErr.Error)
+}
@@ -1,63 +1,125 @@
 package client
 
 import (
-	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net"
+	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"terminal-chat/gifs"
 	"terminal-chat/models"
-	"terminal-chat/utils"
 	"time"
+	"unicode"
 
 	"github.com/gorilla/websocket"
 )
 
+const (
+	writeWait = 10 * time.Second
+	// serverTimeout is how long the server may stay silent, pings included,
+	// before the connection is considered dead. Servers ping every 25s by default.
+	serverTimeout = 90 * time.Second
+)
+
 // Client represents a chat client
 type Client struct {
-	conn     *websocket.Conn
-	username string
-	room     string
-	ui       *UI
-	done     chan struct{}
+	server    *Endpoint
+	username  string                 // As the server knows it; fixed once connected
+	token     string                 // Session token from login, empty for guests
+	login     func() (string, error) // Logs in again for a new token; nil for guests
+	resumeKey string                 // Secret sent on every connect to keep the username
+	welcome   *models.Message        // Greeting from the first connect, nil until then
+	ui        *UI
+	done      chan struct{}
+	closed    sync.Once // Guards disconnect, which quitting and a failed reconnect may both reach
+
+	// mu guards the connection and session state below, which the input
+	// and reader goroutines share; holding it also serialises writes
+	mu      sync.Mutex
+	conn    *websocket.Conn   // nil while disconnected
+	state   string            // Connection state shown in the header
+	outbox  []*models.Message // Messages typed while offline, oldest first
+	rejoin  []string          // Rooms still to rejoin on this connection, oldest first
+	room    string            // Room outgoing messages target
+	rooms   []string          // Rooms joined in this session, in join order
+	joining map[string]bool   // Rooms the user asked to /join, awaiting the server
+
+	sessionID string      // Prefix of the client IDs given to own messages
+	nextID    int         // Counter of the client IDs given to own messages
+	inflight  []*outgoing // Own messages awaiting an ack, oldest first
+
+	uploads   map[string]*upload   // File offers awaiting the server, by client ID
+	downloads map[string]*download // Files being fetched, by file ID
+
+	lastSeq map[string]uint64          // Newest seq seen per room
+	missing map[string]map[uint64]bool // Seqs requested by backfill per room
+
+	readUpTo  map[string]uint64 // Newest seq shown per room
+	readSent  map[string]uint64 // Newest seq reported read per room
+	readTimer *time.Timer       // Pending read report, nil when none is due
+
+	typingRoom  string      // Room last told the user is typing, "" once stopped
+	typingSent  time.Time   // When that room was last told
+	typingTimer *time.Timer // Stops typing once the user pauses
+
+	savedTTY string // Terminal settings to restore, "" when input is line based
 }
 
 // StartClient starts the chat client
-func StartClient(host, port string) {
+func StartClient(cfg *Config) {
 	// ... existing menu code ...
 
+	// Get server address first so the room picker can show live rooms
+	serverAddr, err := ShowConnectionMenu(cfg.DefaultAddr())
+	if err != nil {
+		log.Fatal("Error selecting server:", err)
+	}
+
+	server, err := NewEndpoint(cfg, serverAddr)
+	if err != nil {
+		log.Fatal("Error setting up connection:", err)
+	}
+
 	// Get user input
-	username, room, err := GetUserInput()
+	username, password, room, err := GetUserInput(server)
 	if err != nil {
 		log.Fatal("Error getting user input:", err)
 	}
 
-	// Get server address
-	serverAddr, err := ShowConnectionMenu()
+	// Log in once and keep the token for the rest of the session
+	token, err := authenticate(server, username, password)
 	if err != nil {
-		log.Fatal("Error selecting server:", err)
+		log.Fatal("Login failed: ", err)
 	}
 
 	// Create client
-	client := &Client{
-		username: username,
-		room:     room,
-		done:     make(chan struct{}),
+	client := newClient(server, username, token, room)
+	if token != "" {
+		client.login = relogin(server, username, password)
 	}
 
-	// Connect to server
-	if err := client.connect(serverAddr); err != nil {
+	// Connect to server, which may give us a different name if ours is taken
+	if err := client.connect(); err != nil {
 		log.Fatal("Failed to connect to server:", err)
 	}
 
 	// Initialize UI with fixed input bar
-	client.ui = NewUI(username, room)
+	client.ui = NewUI(client.username, room)
 	client.ui.InitScreen()
+	client.showWelcome()
+
+	// Read keystrokes rather than lines so the room can see the user typing
+	client.enableKeystrokes()
 
 	// Handle graceful shutdown
 	c := make(chan os.Signal, 1)
@@ -75,76 +137,154 @@ func StartClient(host, port string) {
 	client.handleInputWithBar()
 }
 
-// handleInputWithBar handles user input in the fixed input bar
-func (c *Client) handleInputWithBar() {
-	// Enable raw mode for character-by-character input
-	scanner := bufio.NewScanner(os.Stdin)
-
-	for {
-		// Position cursor in input bar
-		c.ui.positionCursorForChat()
-
-		fmt.Print(utils.ColorWhite(""))
-		if scanner.Scan() {
-			input := scanner.Text()
-
-			if input == "" {
-				continue
-			}
-
-			// Clear the input bar
-			c.ui.ClearInput()
-
-			// Handle commands
-			if strings.HasPrefix(input, "/") {
-				if c.handleCommand(input) {
-					break // Exit chat
-				}
-			} else {
-				// Send regular message
-				c.sendMessage(input)
-			}
-		}
+// newClient creates a client that will enter room once connected
+func newClient(server *Endpoint, username, token, room string) *Client {
+	return &Client{
+		server:    server,
+		username:  username,
+		token:     token,
+		resumeKey: newResumeKey(),
+		room:      room,
+		rooms:     []string{room},
+		joining:   make(map[string]bool),
+		done:      make(chan struct{}),
+
+		sessionID: newSessionID(),
+		uploads:   make(map[string]*upload),
+		downloads: make(map[string]*download),
+		lastSeq:   make(map[string]uint64),
+		missing:   make(map[string]map[uint64]bool),
+		readUpTo:  make(map[string]uint64),
+		readSent:  make(map[string]uint64),
 	}
 }
 
 // sendMessage sends a message to the server
 func (c *Client) sendMessage(content string) {
-	if c.conn == nil {
+	room := c.activeRoom()
+	if room == "" {
+		c.showSystemMessage("You are not in any room. Use /join <room> to enter one.")
 		return
 	}
 
-	msg := models.NewMessage(models.MessageTypeChat, c.username, content, c.room)
-
-	c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	c.deliver(models.NewMessage(models.MessageTypeChat, c.username, content, room))
+}
 
-	if err := c.conn.WriteJSON(msg); err != nil {
+// send writes a message to the server. While the connection is down chat
+// traffic is queued for the next reconnect, and while the queue is being
+// sent new chat joins its end; anything else is refused with a notice. It
+// reports whether the message was sent or queued.
+func (c *Client) send(msg *models.Message) bool {
+	c.mu.Lock()
+	behind := queueable(msg.Type) && (len(c.outbox) > 0 || len(c.rejoin) > 0)
+	if c.conn != nil && !behind {
+		c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+		err := c.conn.WriteJSON(msg)
+		if err == nil {
+			c.mu.Unlock()
+			return true
+		}
+		// Closing makes the reader notice the dead connection and reconnect
 		log.Printf("Error sending message: %v", err)
-		return
+		c.conn.Close()
+		c.conn = nil
+	}
+
+	err := errOffline
+	if queueable(msg.Type) {
+		err = errOutboxFull
+		if len(c.outbox) < maxOutbox {
+			c.outbox = append(c.outbox, msg)
+			err = nil
+		}
 	}
+	state, queued := c.state, len(c.outbox)
+	c.mu.Unlock()
+
+	if err != nil {
+		c.showSystemMessage(fmt.Sprintf("Not sent: %v.", err))
+		return false
+	}
+	c.ui.SetConnectionStatus(state, queued)
+	return true
 }
 
-// readMessages handles incoming messages
+// readMessages handles incoming messages, reconnecting whenever the
+// connection drops until the user quits
 func (c *Client) readMessages() {
-	defer c.conn.Close()
-
 	for {
+		c.mu.Lock()
+		conn := c.conn
+		c.mu.Unlock()
+		if conn == nil {
+			return // Disconnected before reading began
+		}
+
+		err := c.readFrom(conn)
+
 		select {
 		case <-c.done:
 			return
 		default:
-			_, message, err := c.conn.ReadMessage()
-			if err != nil {
-				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-					log.Printf("WebSocket error: %v", err)
-				}
-				c.ui.ShowDisconnected()
-				return
-			}
+		}
+
+		if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+			log.Printf("WebSocket error: %v", err)
+		}
+		c.mu.Lock()
+		if c.conn == conn {
+			c.conn = nil
+		}
+		// The server forgets typing with the connection; announce it afresh
+		c.typingRoom = ""
+		c.mu.Unlock()
+		c.failUnacked()
+		c.abortTransfers()
+		c.ui.ClearTyping("")
+
+		retry := shouldReconnect(err)
+		c.ui.ShowDisconnected(closeReason(err), retry)
+		if !retry {
+			c.setState(stateOffline)
+			return
+		}
+		if !c.reconnect() {
+			return
+		}
+		c.showSystemMessage("Reconnected.")
+	}
+}
 
-			c.processMessage(message)
+// readFrom processes messages from one connection until it fails
+func (c *Client) readFrom(conn *websocket.Conn) error {
+	defer conn.Close()
+
+	for {
+		kind, message, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		conn.SetReadDeadline(time.Now().Add(serverTimeout))
+
+		// Binary frames carry the chunks of downloads
+		if kind == websocket.BinaryMessage {
+			c.receiveChunk(message)
+			continue
 		}
+		c.processMessage(message)
+	}
+}
+
+// closeReason explains a read error to the user, with the server's reason if it gave one
+func closeReason(err error) string {
+	var closeErr *websocket.CloseError
+	var netErr net.Error
+	if errors.As(err, &closeErr) {
+		return closeErr.Text
+	} else if errors.As(err, &netErr) && netErr.Timeout() {
+		return "server stopped responding"
 	}
+	return ""
 }
 
 // processMessage processes incoming messages
@@ -153,14 +293,111 @@ func (c *Client) processMessage(message []byte) {
 	if err := json.Unmarshal(message, &msg); err != nil {
 		return
 	}
+	if !c.acceptSeq(&msg) {
+		return
+	}
+	c.noteRead(&msg)
 
-	// Display message in chat area (not mixed with input)
-	c.ui.DisplayMessage(msg)
+	switch msg.Type {
+	case models.MessageTypeAck:
+		c.markDelivered(msg.ClientID)
+		return
+
+	case models.MessageTypeEdit, models.MessageTypeDelete:
+		c.ui.ApplyChange(msg)
+		return
+
+	case models.MessageTypeReaction:
+		c.ui.ApplyReactions(msg)
+		return
+
+	case models.MessageTypeError:
+		if msg.ClientID != "" {
+			c.markFailed(msg.ClientID)
+		}
+		c.cancelTransfer(&msg)
+		// Errors without text only mark the message they refer to as failed
+		if msg.Content == "" {
+			return
+		}
+		if refusedEntry(msg.Code) {
+			c.dropRoom(msg.Room)
+		}
+
+	case models.MessageTypeChat, models.MessageTypeGIF, models.MessageTypeDirect:
+		if msg.Username == c.username && msg.ClientID != "" {
+			// A replayed copy of a message lost with the connection proves
+			// it did arrive before the drop
+			if msg.History {
+				c.markDelivered(msg.ClientID)
+			}
+			// The server's copy of a message already shown locally
+			if c.ui.ReplaceMessage(msg) {
+				return
+			}
+		}
+
+	case models.MessageTypeFileOffer:
+		c.startUpload(&msg)
+		return
+
+	case models.MessageTypeFileRequest:
+		if msg.File != nil {
+			c.beginDownload(msg.File)
+		}
+		return
+
+	case models.MessageTypeUserList:
+		// Handle user list updates
+		c.ui.UpdateUserList(msg.Room, msg.Users)
+		return
 
-	// Handle user list updates
-	if msg.Type == models.MessageTypeUserList {
-		c.ui.UpdateUserList([]string{})
+	case models.MessageTypeJoin:
+		// Our own join is the server confirming a /join
+		if msg.Username == c.username {
+			c.addRoom(msg.Room)
+			c.syncHead(msg.Room, msg.Seq)
+		}
+
+	case models.MessageTypeLeave:
+		if msg.Username == c.username {
+			c.removeRoom(msg.Room)
+			c.forgetSeq(msg.Room)
+			c.ui.ClearTyping(msg.Room)
+			c.ui.ClearReadMark(msg.Room)
+		}
+
+	case models.MessageTypeRoomList:
+		c.ui.ShowRoomList(msg.Rooms)
+		return
+
+	case models.MessageTypeRead:
+		c.ui.SetReadMark(msg.Room, msg.Seq)
+		return
+
+	case models.MessageTypeReceipts:
+		c.ui.ShowReceipts(msg)
+		return
+
+	case models.MessageTypeTopic:
+		if msg.RoomInfo != nil {
+			c.ui.SetRoomInfo(*msg.RoomInfo)
+		}
+		if msg.Content == "" {
+			return
+		}
+		// Changes are announced like any other room event
+		msg.Type = models.MessageTypeSystem
+
+	case models.MessageTypeTypingStart, models.MessageTypeTypingStop:
+		if msg.Username != c.username {
+			c.ui.SetTyping(msg.Room, msg.Username, msg.Type == models.MessageTypeTypingStart)
+		}
+		return
 	}
+
+	// Display message in chat area (not mixed with input)
+	c.ui.DisplayMessage(msg)
 }
 
 // handleCommand processes chat commands
@@ -192,6 +429,60 @@ func (c *Client) handleCommand(command string) bool {
 	case "/gifs":
 		c.showAvailableGIFs()
 
+	case "/join":
+		c.handleJoinCommand(parts)
+
+	case "/part":
+		c.handlePartCommand(parts)
+
+	case "/msg":
+		c.handleDirectMessageCommand(command, parts)
+
+	case "/retry":
+		c.handleRetryCommand()
+
+	case "/edit":
+		c.handleEditCommand(command)
+
+	case "/delete":
+		c.handleDeleteCommand(command)
+
+	case "/react":
+		c.handleReactCommand(command)
+
+	case "/reply":
+		c.handleReplyCommand(command)
+
+	case "/thread":
+		c.handleThreadCommand(command)
+
+	case "/back":
+		c.ui.ShowRoom()
+
+	case "/seen":
+		c.handleSeenCommand(command)
+
+	case "/topic":
+		c.handleTopicCommand(command)
+
+	case "/describe":
+		c.handleDescribeCommand(command)
+
+	case "/send":
+		c.handleSendCommand(command)
+
+	case "/download":
+		c.handleDownloadCommand(command)
+
+	case "/private", "/public", "/passphrase", "/invite":
+		c.handleAccessCommand(strings.TrimPrefix(cmd, "/"), command)
+
+	case "/kick", "/ban", "/unban", "/mute", "/unmute", "/op", "/deop":
+		c.handleModerationCommand(strings.TrimPrefix(cmd, "/"), command)
+
+	case "/rooms":
+		c.send(models.NewMessage(models.MessageTypeRoomList, c.username, "", ""))
+
 	default:
 		systemMsg := models.Message{
 			Type:      models.MessageTypeSystem,
@@ -204,6 +495,205 @@ func (c *Client) handleCommand(command string) bool {
 
 	return false
 }
+
+// handleJoinCommand joins a room, or switches to it if already joined
+func (c *Client) handleJoinCommand(parts []string) {
+	if len(parts) < 2 {
+		c.showSystemMessage("Usage: /join <room> [passphrase or invite]")
+		return
+	}
+
+	room := parts[1]
+	if c.inRoom(room) {
+		c.setActiveRoom(room)
+		c.showSystemMessage(fmt.Sprintf("Now talking in #%s", room))
+		return
+	}
+
+	c.mu.Lock()
+	c.joining[room] = true
+	c.mu.Unlock()
+
+	join := models.NewMessage(models.MessageTypeJoinRoom, c.username, "", room)
+	if len(parts) > 2 {
+		join.Key = parts[2]
+	}
+	if !c.send(join) {
+		c.mu.Lock()
+		delete(c.joining, room)
+		c.mu.Unlock()
+	}
+}
+
+// handlePartCommand leaves the named room, or the active one
+func (c *Client) handlePartCommand(parts []string) {
+	room := c.activeRoom()
+	if len(parts) >= 2 {
+		room = parts[1]
+	}
+
+	if !c.inRoom(room) {
+		c.showSystemMessage(fmt.Sprintf("You are not in #%s", room))
+		return
+	}
+
+	c.send(models.NewMessage(models.MessageTypePartRoom, c.username, "", room))
+}
+
+// handleEditCommand replaces the text of a message: /edit [^N] <text>
+func (c *Client) handleEditCommand(command string) {
+	target, text, ok := c.targetMessage(command, true)
+	if !ok {
+		return
+	}
+	if text == "" {
+		c.showSystemMessage("Usage: /edit [^N] <new text>")
+		return
+	}
+
+	msg := models.NewMessage(models.MessageTypeEdit, c.username, text, target.Room)
+	msg.Target = target.ID
+	c.send(msg)
+}
+
+// handleDeleteCommand removes a message: /delete [^N]
+func (c *Client) handleDeleteCommand(command string) {
+	target, _, ok := c.targetMessage(command, true)
+	if !ok {
+		return
+	}
+
+	msg := models.NewMessage(models.MessageTypeDelete, c.username, "", target.Room)
+	msg.Target = target.ID
+	c.send(msg)
+}
+
+// handleDirectMessageCommand sends a private message: /msg <user> <text>
+func (c *Client) handleDirectMessageCommand(command string, parts []string) {
+	if len(parts) < 3 {
+		c.showSystemMessage("Usage: /msg <user> <message>")
+		return
+	}
+
+	fields, content := splitCommand(command, 2)
+	recipient := fields[1]
+
+	msg := models.NewMessage(models.MessageTypeDirect, c.username, content, "")
+	msg.Recipient = recipient
+	c.deliver(msg)
+}
+
+// splitCommand splits off the first n fields of a command and returns them
+// together with the rest of the line exactly as typed
+func splitCommand(command string, n int) ([]string, string) {
+	rest := strings.TrimSpace(command)
+	fields := make([]string, 0, n)
+	for len(fields) < n && rest != "" {
+		end := strings.IndexFunc(rest, unicode.IsSpace)
+		if end < 0 {
+			fields = append(fields, rest)
+			rest = ""
+			break
+		}
+		fields = append(fields, rest[:end])
+		rest = strings.TrimSpace(rest[end:])
+	}
+	return fields, rest
+}
+
+// activeRoom returns the room outgoing messages target
+func (c *Client) activeRoom() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.room
+}
+
+// inRoom reports whether this session has joined a room
+func (c *Client) inRoom(room string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.joined(room)
+}
+
+// joined is inRoom for callers already holding c.mu
+func (c *Client) joined(room string) bool {
+	for _, joined := range c.rooms {
+		if joined == room {
+			return true
+		}
+	}
+	return false
+}
+
+// addRoom records a confirmed join. A room the user asked for becomes the
+// active one; rejoins after a reconnect leave the active room alone.
+func (c *Client) addRoom(room string) {
+	c.mu.Lock()
+	if !c.joined(room) {
+		c.rooms = append(c.rooms, room)
+	}
+	if c.joining[room] || c.room == "" {
+		c.room = room
+	}
+	delete(c.joining, room)
+	active, rooms := c.room, append([]string(nil), c.rooms...)
+	c.mu.Unlock()
+
+	c.ui.SetRooms(active, rooms)
+}
+
+// removeRoom records a confirmed part, falling back to the last joined room
+func (c *Client) removeRoom(room string) {
+	c.mu.Lock()
+	for i, joined := range c.rooms {
+		if joined == room {
+			c.rooms = append(c.rooms[:i], c.rooms[i+1:]...)
+			break
+		}
+	}
+	if c.room == room {
+		c.room = ""
+		if len(c.rooms) > 0 {
+			c.room = c.rooms[len(c.rooms)-1]
+		}
+	}
+	active, rooms := c.room, append([]string(nil), c.rooms...)
+	c.mu.Unlock()
+
+	c.ui.SetRooms(active, rooms)
+	if active == "" {
+		c.showSystemMessage("You are not in any room. Use /join <room> to enter one.")
+	}
+}
+
+// dropRoom forgets a room the server would not let us into. It may be the
+// room we connected to, which was never confirmed.
+func (c *Client) dropRoom(room string) {
+	c.mu.Lock()
+	delete(c.joining, room)
+	joined := c.joined(room)
+	c.mu.Unlock()
+
+	if joined {
+		c.removeRoom(room)
+	}
+}
+
+// setActiveRoom changes the room outgoing messages are sent to
+func (c *Client) setActiveRoom(room string) {
+	c.mu.Lock()
+	c.room = room
+	rooms := append([]string(nil), c.rooms...)
+	c.mu.Unlock()
+
+	c.ui.SetRooms(room, rooms)
+}
+
+// showSystemMessage prints a local notice in the chat area
+func (c *Client) showSystemMessage(content string) {
+	c.ui.showNotice(content)
+}
+
 func (c *Client) handleGIFCommand(parts []string) {
 	if len(parts) < 2 {
 		systemMsg := models.Message{
@@ -233,16 +723,13 @@ func (c *Client) handleGIFCommand(parts []string) {
 		Type:      models.MessageTypeGIF,
 		Username:  c.username,
 		Content:   fmt.Sprintf("sent a GIF: %s", gifName),
-		Room:      c.room,
+		Room:      c.activeRoom(),
 		Timestamp: time.Now(),
 		GIFName:   gifName,
 		IsGIF:     true,
 	}
 
-	if err := c.conn.WriteJSON(msg); err != nil {
-		log.Printf("Error sending GIF: %v", err)
-		return
-	}
+	c.deliver(&msg)
 }
 
 // showAvailableGIFs shows list of available GIFs
@@ -259,35 +746,99 @@ func (c *Client) showAvailableGIFs() {
 	c.ui.DisplayMessage(systemMsg)
 }
 
-// connect and disconnect methods remain the same...
-func (c *Client) connect(serverAddr string) error {
-	u := url.URL{
-		Scheme: "ws",
-		Host:   serverAddr,
-		Path:   "/ws",
-	}
+// connect dials the server and, when reconnecting, restores the session
+func (c *Client) connect() error {
+	c.mu.Lock()
+	room := c.room
+	since := c.lastSeq[room]
+	c.mu.Unlock()
 
-	q := u.Query()
+	q := url.Values{}
 	q.Set("username", c.username)
-	q.Set("room", c.room)
-	u.RawQuery = q.Encode()
+	q.Set("room", room)
+	q.Set("resume", c.resumeKey)
+	if since > 0 {
+		// Catch up on what was missed instead of replaying history
+		q.Set("since", strconv.FormatUint(since, 10))
+	}
+
+	header := http.Header{}
+	if c.token != "" {
+		header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	conn, resp, err := c.server.Dialer().Dial(c.server.WebSocketURL(q), header)
+	if resp != nil && resp.StatusCode >= 400 && resp.StatusCode < 500 {
+		reason, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%w: %s", errLoginRefused, strings.TrimSpace(string(reason)))
+	}
+	if err != nil {
+		return err
+	}
 
-	conn, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	// Answer the server's pings and treat each one as proof of life, so a
+	// dead server is noticed within serverTimeout instead of never
+	conn.SetReadDeadline(time.Now().Add(serverTimeout))
+	conn.SetPingHandler(func(data string) error {
+		conn.SetReadDeadline(time.Now().Add(serverTimeout))
+		err := conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(writeWait))
+		if err == websocket.ErrCloseSent {
+			return nil
+		}
+		return err
+	})
+
+	welcome, err := readWelcome(conn)
 	if err != nil {
+		conn.Close()
 		return err
 	}
+	if c.welcome == nil {
+		// Nothing else runs before the first connect, so the name is ours to set
+		c.username = welcome.Username
+		c.welcome = welcome
+	} else if welcome.Username != c.username {
+		conn.Close()
+		return fmt.Errorf("%w by someone else", errNameTaken)
+	}
 
+	limits := defaultLimits
+	if welcome.Limits != nil {
+		limits = *welcome.Limits
+	}
+
+	c.mu.Lock()
 	c.conn = conn
+	// The dialed room is joined already; the others follow through resume
+	c.rejoin = slices.DeleteFunc(slices.Clone(c.rooms), func(r string) bool { return r == room })
+	if len(c.rejoin) > 0 || len(c.outbox) > 0 {
+		go c.resume(conn, limits)
+	}
+	c.mu.Unlock()
+
+	c.setState(stateConnected)
 	return nil
 }
 
+// disconnect ends the session; calls after the first do nothing
 func (c *Client) disconnect() {
+	c.closed.Do(c.shutdown)
+}
+
+// shutdown closes the connection and restores the terminal
+func (c *Client) shutdown() {
 	close(c.done)
+	c.stopTyping()
+	c.restoreTerminal()
 
+	c.mu.Lock()
 	if c.conn != nil {
+		c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 		c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
 		c.conn.Close()
+		c.conn = nil
 	}
+	c.mu.Unlock()
 
 	if c.ui != nil {
 		c.ui.ShowGoodbye()
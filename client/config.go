@@ -0,0 +1,44 @@
+package client
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+)
+
+// Config holds the client connection settings
+type Config struct {
+	Host string
+	Port string
+
+	TLS            bool   // Dial wss:// and https:// instead of ws:// and http://
+	CAFile         string // Extra PEM CA bundle trusted for the server certificate
+	KnownHostsFile string // Certificate fingerprints pinned on first use
+}
+
+// DefaultConfig returns the settings used when no flags are given
+func DefaultConfig() *Config {
+	knownHosts := "known_hosts"
+	if home, err := os.UserHomeDir(); err == nil {
+		knownHosts = filepath.Join(home, ".terminal-chat", "known_hosts")
+	}
+
+	return &Config{
+		Host:           "localhost",
+		Port:           "8080",
+		KnownHostsFile: knownHosts,
+	}
+}
+
+// BindFlags registers the client settings on a flag set.
+// Host and port are left to the caller since the combined binary shares them with the server.
+func (c *Config) BindFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&c.TLS, "tls", c.TLS, "Connect with TLS (wss://)")
+	fs.StringVar(&c.CAFile, "ca", c.CAFile, "PEM CA bundle to trust for the server certificate")
+	fs.StringVar(&c.KnownHostsFile, "known-hosts", c.KnownHostsFile, "File of pinned server certificate fingerprints")
+}
+
+// DefaultAddr is the host:port given on the command line
+func (c *Config) DefaultAddr() string {
+	return c.Host + ":" + c.Port
+}
@@ -0,0 +1,158 @@
+package client
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"terminal-chat/models"
+	"terminal-chat/utils"
+)
+
+// deliveryState tracks one of the user's own messages until the server acks it
+type deliveryState int
+
+const (
+	deliveryPending deliveryState = iota // Sent or queued, no ack yet
+	deliverySent                         // Acked by the server
+	deliveryFailed                       // Rejected, or lost with the connection
+)
+
+// outgoing is an own message the server has not acknowledged yet
+type outgoing struct {
+	msg    *models.Message
+	failed bool
+}
+
+// newSessionID returns the prefix that keeps client IDs unique across runs
+func newSessionID() string {
+	buf := make([]byte, 4)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// newResumeKey returns the secret that proves a reconnect is this client,
+// letting it keep its name while the server still holds the dead connection
+func newResumeKey() string {
+	return rand.Text()
+}
+
+// deliver sends one of the user's own messages, showing it at once with a
+// pending marker that the server's ack or error later resolves
+func (c *Client) deliver(msg *models.Message) {
+	c.mu.Lock()
+	c.nextID++
+	msg.ClientID = fmt.Sprintf("%s-%d", c.sessionID, c.nextID)
+	c.inflight = append(c.inflight, &outgoing{msg: msg})
+	c.mu.Unlock()
+
+	c.ui.SetDelivery(msg.ClientID, deliveryPending)
+	c.ui.DisplayMessage(*msg)
+	if !c.send(msg) {
+		c.markFailed(msg.ClientID)
+	}
+}
+
+// markDelivered resolves an in-flight message once the server confirms it
+func (c *Client) markDelivered(clientID string) {
+	c.mu.Lock()
+	found := false
+	for i, out := range c.inflight {
+		if out.msg.ClientID == clientID {
+			c.inflight = append(c.inflight[:i], c.inflight[i+1:]...)
+			found = true
+			break
+		}
+	}
+	c.mu.Unlock()
+
+	if found {
+		c.ui.SetDelivery(clientID, deliverySent)
+	}
+}
+
+// markFailed flags an in-flight message so /retry can send it again
+func (c *Client) markFailed(clientID string) {
+	c.mu.Lock()
+	found := false
+	for _, out := range c.inflight {
+		if out.msg.ClientID == clientID {
+			out.failed = true
+			found = true
+			break
+		}
+	}
+	c.mu.Unlock()
+
+	if found {
+		c.ui.SetDelivery(clientID, deliveryFailed)
+	}
+}
+
+// failUnacked marks messages that were written to a connection that then
+// died without acking them. Queued messages stay pending: they go out on
+// the next connection.
+func (c *Client) failUnacked() {
+	c.mu.Lock()
+	queued := make(map[*models.Message]bool, len(c.outbox))
+	for _, msg := range c.outbox {
+		queued[msg] = true
+	}
+	var lost []string
+	for _, out := range c.inflight {
+		if !out.failed && !queued[out.msg] {
+			out.failed = true
+			lost = append(lost, out.msg.ClientID)
+		}
+	}
+	c.mu.Unlock()
+
+	for _, clientID := range lost {
+		c.ui.SetDelivery(clientID, deliveryFailed)
+	}
+}
+
+// handleRetryCommand sends every failed message again, oldest first
+func (c *Client) handleRetryCommand() {
+	c.mu.Lock()
+	var retry []*models.Message
+	for _, out := range c.inflight {
+		if out.failed {
+			out.failed = false
+			retry = append(retry, out.msg)
+		}
+	}
+	c.mu.Unlock()
+
+	if len(retry) == 0 {
+		c.showSystemMessage("No failed messages to retry.")
+		return
+	}
+
+	for _, msg := range retry {
+		c.ui.SetDelivery(msg.ClientID, deliveryPending)
+		if !c.send(msg) {
+			c.markFailed(msg.ClientID)
+		}
+	}
+}
+
+// deliveryMarker returns the marker for an own message and its printed
+// width, or nothing for messages this session did not send
+func (ui *UI) deliveryMarker(msg models.Message) (string, int) {
+	if msg.Username != ui.username || msg.ClientID == "" {
+		return "", 0
+	}
+	state, tracked := ui.delivery[msg.ClientID]
+	if !tracked {
+		return "", 0
+	}
+
+	switch state {
+	case deliverySent:
+		return " " + utils.ColorGreen("✓"), 2
+	case deliveryFailed:
+		return " " + utils.ColorRed("✗ failed, /retry"), 17
+	default:
+		return " " + utils.ColorFaint("…"), 2
+	}
+}
@@ -0,0 +1,59 @@
+package client
+
+import (
+	"terminal-chat/models"
+	"terminal-chat/server"
+	"testing"
+	"time"
+)
+
+// deliveryOf returns the marker state of an own message
+func (c *Client) deliveryOf(clientID string) (deliveryState, bool) {
+	c.ui.mu.Lock()
+	defer c.ui.mu.Unlock()
+	state, ok := c.ui.delivery[clientID]
+	return state, ok
+}
+
+// waitDelivery waits until an own message reaches a delivery state
+func waitDelivery(t *testing.T, c *Client, clientID string, want deliveryState) {
+	t.Helper()
+	waitFor(t, 2*time.Second, "message "+clientID+" to be marked", func() bool {
+		state, _ := c.deliveryOf(clientID)
+		return state == want
+	})
+}
+
+func TestOwnMessagesAreAcked(t *testing.T) {
+	ep := newTestServer(t, server.DefaultConfig())
+	alice := newTestClient(t, ep, "alice", "general")
+	alice.start(t)
+
+	msg := models.NewMessage(models.MessageTypeChat, alice.username, "hello", "general")
+	alice.deliver(msg)
+	if state, ok := alice.deliveryOf(msg.ClientID); !ok || state == deliveryFailed {
+		t.Fatalf("a message just sent is marked %v", state)
+	}
+	waitDelivery(t, alice, msg.ClientID, deliverySent)
+
+	alice.mu.Lock()
+	defer alice.mu.Unlock()
+	if len(alice.inflight) != 0 {
+		t.Errorf("%d messages still await an ack", len(alice.inflight))
+	}
+}
+
+func TestRefusedMessagesFailAndRetry(t *testing.T) {
+	ep := newTestServer(t, server.DefaultConfig())
+	alice := newTestClient(t, ep, "alice", "general")
+	alice.start(t)
+
+	// Rooms the user has not joined refuse their messages
+	msg := models.NewMessage(models.MessageTypeChat, alice.username, "anyone?", "elsewhere")
+	alice.deliver(msg)
+	waitDelivery(t, alice, msg.ClientID, deliveryFailed)
+
+	msg.Room = "general"
+	alice.handleRetryCommand()
+	waitDelivery(t, alice, msg.ClientID, deliverySent)
+}
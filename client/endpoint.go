@@ -0,0 +1,209 @@
+package client
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"terminal-chat/utils"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/manifoldco/promptui"
+)
+
+// Endpoint is one chat server together with how to reach it securely
+type Endpoint struct {
+	Addr      string
+	Secure    bool
+	tlsConfig *tls.Config
+}
+
+// NewEndpoint prepares plain or TLS access to the server at addr
+func NewEndpoint(cfg *Config, addr string) (*Endpoint, error) {
+	ep := &Endpoint{Addr: addr, Secure: cfg.TLS}
+	if !cfg.TLS {
+		return ep, nil
+	}
+
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+	if cfg.CAFile != "" {
+		pemData, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		if !roots.AppendCertsFromPEM(pemData) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.CAFile)
+		}
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	pins := &pinStore{path: cfg.KnownHostsFile}
+	ep.tlsConfig = &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: host,
+		// Chain verification happens in VerifyConnection so that an
+		// untrusted certificate can fall back to fingerprint pinning
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			return verifyServer(cs, roots, host, addr, pins)
+		},
+	}
+	return ep, nil
+}
+
+// URL builds an http(s) URL for an API path on the server
+func (e *Endpoint) URL(path string) string {
+	scheme := "http"
+	if e.Secure {
+		scheme = "https"
+	}
+	u := url.URL{Scheme: scheme, Host: e.Addr, Path: path}
+	return u.String()
+}
+
+// WebSocketURL builds the ws(s) URL for the chat endpoint
+func (e *Endpoint) WebSocketURL(query url.Values) string {
+	scheme := "ws"
+	if e.Secure {
+		scheme = "wss"
+	}
+	u := url.URL{Scheme: scheme, Host: e.Addr, Path: "/ws", RawQuery: query.Encode()}
+	return u.String()
+}
+
+// HTTPClient returns a client for API calls that trusts the same certificates
+func (e *Endpoint) HTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{TLSClientConfig: e.tlsConfig},
+	}
+}
+
+// Dialer returns a WebSocket dialer that trusts the same certificates
+func (e *Endpoint) Dialer() *websocket.Dialer {
+	return &websocket.Dialer{
+		HandshakeTimeout: 10 * time.Second,
+		TLSClientConfig:  e.tlsConfig,
+	}
+}
+
+// verifyServer accepts a certificate that chains to a trusted CA, or one
+// whose fingerprint the user has pinned for this server
+func verifyServer(cs tls.ConnectionState, roots *x509.CertPool, host, addr string, pins *pinStore) error {
+	if len(cs.PeerCertificates) == 0 {
+		return fmt.Errorf("server sent no certificate")
+	}
+	leaf := cs.PeerCertificates[0]
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, chainErr := leaf.Verify(x509.VerifyOptions{
+		DNSName:       host,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	if chainErr == nil {
+		return nil
+	}
+
+	fingerprint := utils.CertFingerprint(leaf.Raw)
+	pinned, err := pins.lookup(addr)
+	if err != nil {
+		return err
+	}
+	if pinned == fingerprint {
+		return nil
+	}
+	if pinned != "" {
+		return fmt.Errorf("certificate for %s has CHANGED since it was pinned (was %s, now %s); "+
+			"refusing to connect - remove the entry from %s if the change is expected",
+			addr, pinned, fingerprint, pins.path)
+	}
+
+	if !confirmFingerprint(addr, leaf, fingerprint, chainErr) {
+		return fmt.Errorf("certificate for %s not trusted: %v", addr, chainErr)
+	}
+	return pins.add(addr, fingerprint)
+}
+
+// confirmFingerprint asks the user whether to trust an unverified certificate
+func confirmFingerprint(addr string, leaf *x509.Certificate, fingerprint string, reason error) bool {
+	fmt.Printf("\n%s The certificate of %s could not be verified: %v\n",
+		utils.ColorWarning("⚠️"), utils.ColorBold(addr), reason)
+	fmt.Printf("   Subject:     %s\n", leaf.Subject.CommonName)
+	fmt.Printf("   Valid until: %s\n", leaf.NotAfter.Format("2006-01-02"))
+	fmt.Printf("   SHA-256:     %s\n", utils.ColorYellow(fingerprint))
+	fmt.Println("   Compare this fingerprint with the one printed by the server before trusting it.")
+
+	prompt := promptui.Prompt{
+		Label:     "Trust and pin this certificate",
+		IsConfirm: true,
+	}
+	_, err := prompt.Run()
+	return err == nil
+}
+
+// pinStore reads and appends "host:port fingerprint" lines in a known_hosts file
+type pinStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// lookup returns the pinned fingerprint for addr, or "" when there is none
+func (p *pinStore) lookup(addr string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	file, err := os.Open(p.path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == addr {
+			return fields[1], nil
+		}
+	}
+	return "", scanner.Err()
+}
+
+// add pins a fingerprint for addr
+func (p *pinStore) add(addr, fingerprint string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(p.path), 0o700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(p.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = fmt.Fprintf(file, "%s %s\n", addr, fingerprint)
+	return err
+}
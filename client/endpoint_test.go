@@ -0,0 +1,64 @@
+package client
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"terminal-chat/utils"
+	"testing"
+	"time"
+)
+
+// get fetches the server's root through ep
+func get(ep *Endpoint) error {
+	resp, err := ep.HTTPClient(time.Second).Get(ep.URL("/"))
+	if err == nil {
+		resp.Body.Close()
+	}
+	return err
+}
+
+func TestEndpointTrust(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(srv.Close)
+	addr := strings.TrimPrefix(srv.URL, "https://")
+	dir := t.TempDir()
+
+	// A CA bundle that signs the certificate needs no pin
+	caFile := filepath.Join(dir, "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ep, err := NewEndpoint(&Config{TLS: true, CAFile: caFile, KnownHostsFile: filepath.Join(dir, "none")}, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := get(ep); err != nil {
+		t.Fatalf("with the CA bundle: %v", err)
+	}
+
+	// Without it, the pinned fingerprint has to match
+	knownHosts := filepath.Join(dir, "known_hosts")
+	pins := &pinStore{path: knownHosts}
+	if err := pins.add(addr, utils.CertFingerprint(srv.Certificate().Raw)); err != nil {
+		t.Fatal(err)
+	}
+	ep, err = NewEndpoint(&Config{TLS: true, KnownHostsFile: knownHosts}, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := get(ep); err != nil {
+		t.Fatalf("with the pinned fingerprint: %v", err)
+	}
+
+	if err := os.WriteFile(knownHosts, []byte(addr+" AA:BB\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := get(ep); err == nil || !strings.Contains(err.Error(), "CHANGED") {
+		t.Fatalf("a changed certificate gave %v", err)
+	}
+}
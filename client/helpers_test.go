@@ -0,0 +1,97 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"terminal-chat/server"
+	"testing"
+	"time"
+
+	"github.com/pterm/pterm"
+)
+
+// TestMain keeps the chat screen the client draws out of the test output
+func TestMain(m *testing.M) {
+	pterm.DisableOutput()
+	os.Exit(m.Run())
+}
+
+// newTestServer starts a chat server with an in-memory history on a local
+// port, stops it when the test ends and returns where to reach it
+func newTestServer(t *testing.T, cfg *server.Config) *Endpoint {
+	t.Helper()
+	ep, _ := newTestServerWithAccounts(t, cfg)
+	return ep
+}
+
+// newTestServerWithAccounts is newTestServer that also returns the
+// server's accounts
+func newTestServerWithAccounts(t *testing.T, cfg *server.Config) (*Endpoint, *server.AccountStore) {
+	t.Helper()
+	dir := t.TempDir()
+	accounts, err := server.NewAccountStore(dir+"/accounts.json", cfg.SessionTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readMarks, err := server.NewReadMarks("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	roomStates, err := server.NewRoomStates(dir + "/rooms.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := server.NewFileStore(dir+"/files", cfg.FileStoreSize, cfg.FileTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub := server.NewHub(cfg, server.NewMemoryStore(), accounts, readMarks, roomStates, files)
+	go hub.Run()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) { server.ServeWS(hub, accounts, w, r) })
+	srv := httptest.NewServer(mux)
+
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		hub.Shutdown(ctx, "test over")
+		srv.Close()
+	})
+	return &Endpoint{Addr: strings.TrimPrefix(srv.URL, "http://")}, accounts
+}
+
+// newTestClient creates a client of the server at ep with a screen that
+// draws nowhere. It is not connected yet.
+func newTestClient(t *testing.T, ep *Endpoint, username, room string) *Client {
+	t.Helper()
+	c := newClient(ep, username, "", room)
+	c.ui = NewUI(username, room)
+	return c
+}
+
+// start connects the client and reads from the server in the background
+// until the test ends
+func (c *Client) start(t *testing.T) {
+	t.Helper()
+	if err := c.connect(); err != nil {
+		t.Fatal(err)
+	}
+	go c.readMessages()
+	t.Cleanup(c.disconnect)
+}
+
+// waitFor polls cond until it holds, failing the test after timeout
+func waitFor(t *testing.T, timeout time.Duration, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,151 @@
+package client
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"unicode"
+)
+
+// enableKeystrokes turns off line buffering and echo on the terminal, so
+// the input loop sees every key as it is typed. Where stty is missing, as
+// on Windows, input stays line based and no typing signals are sent.
+func (c *Client) enableKeystrokes() {
+	if runtime.GOOS == "windows" {
+		return
+	}
+	saved, err := stty("-g")
+	if err != nil {
+		return
+	}
+	if _, err := stty("-icanon", "-echo", "min", "1"); err != nil {
+		return
+	}
+	c.savedTTY = strings.TrimSpace(saved)
+}
+
+// restoreTerminal puts back the terminal settings enableKeystrokes changed
+func (c *Client) restoreTerminal() {
+	if c.savedTTY != "" {
+		stty(c.savedTTY)
+	}
+}
+
+// stty runs stty against the terminal on stdin and returns its output
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// handleInputWithBar handles user input in the fixed input bar
+func (c *Client) handleInputWithBar() {
+	if c.savedTTY == "" {
+		c.readLines()
+		return
+	}
+	c.readKeys()
+}
+
+// readKeys edits the input line key by key, drawing it in the input bar
+// and telling the room while the user types. The UI's lock keeps those
+// redraws from landing in the middle of the reader's.
+func (c *Client) readKeys() {
+	reader := bufio.NewReader(os.Stdin)
+	var line []rune
+
+	c.ui.positionCursorForChat()
+	for {
+		r, _, err := reader.ReadRune()
+		if err != nil {
+			return
+		}
+
+		switch {
+		case r == '\r' || r == '\n':
+			input := string(line)
+			line = line[:0]
+			c.ui.ClearInput()
+			c.stopTyping()
+			if input != "" && c.submit(input) {
+				return // Exit chat
+			}
+			continue
+
+		case r == 127 || r == '\b': // Backspace
+			if len(line) > 0 {
+				line = line[:len(line)-1]
+			}
+
+		case r == 21: // Ctrl-U clears the line
+			line = line[:0]
+
+		case r == 27: // Arrow keys and the like are not supported
+			skipEscape(reader)
+			continue
+
+		case unicode.IsControl(r):
+			continue
+
+		default:
+			line = append(line, r)
+		}
+
+		c.ui.UpdateInputBar(string(line))
+		c.noteTyping(string(line))
+	}
+}
+
+// skipEscape swallows the rest of an escape sequence such as an arrow key.
+// A lone Escape press has nothing buffered after it.
+func skipEscape(reader *bufio.Reader) {
+	if reader.Buffered() == 0 {
+		return
+	}
+	if next, _, err := reader.ReadRune(); err != nil || (next != '[' && next != 'O') {
+		return
+	}
+	for {
+		r, _, err := reader.ReadRune()
+		if err != nil || (r >= 0x40 && r <= 0x7e) {
+			return
+		}
+	}
+}
+
+// readLines reads whole lines where the terminal cannot be read key by key
+func (c *Client) readLines() {
+	scanner := bufio.NewScanner(os.Stdin)
+
+	for {
+		// Position cursor in input bar
+		c.ui.positionCursorForChat()
+
+		if scanner.Scan() {
+			input := scanner.Text()
+
+			if input == "" {
+				continue
+			}
+
+			// Clear the input bar
+			c.ui.ClearInput()
+
+			if c.submit(input) {
+				break // Exit chat
+			}
+		}
+	}
+}
+
+// submit runs a command or sends a message, and reports whether the user quit
+func (c *Client) submit(input string) bool {
+	if strings.HasPrefix(input, "/") {
+		return c.handleCommand(input)
+	}
+	c.sendMessage(input)
+	return false
+}
@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"terminal-chat/models"
 	"terminal-chat/utils"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/manifoldco/promptui"
 	"github.com/pterm/pterm"
@@ -71,8 +74,9 @@ func ShowMainMenu() (*MenuOption, error) {
 	return options[index], nil
 }
 
-// GetUserInput prompts for user input with validation
-func GetUserInput() (string, string, error) {
+// GetUserInput prompts for username, password and room. An empty password
+// means joining as a guest.
+func GetUserInput(server *Endpoint) (string, string, string, error) {
 	// Username input with validation
 	usernamePrompt := promptui.Prompt{
 		Label: "Enter your username",
@@ -80,9 +84,12 @@ func GetUserInput() (string, string, error) {
 			if len(input) < 1 {
 				return fmt.Errorf("username cannot be empty")
 			}
-			if len(input) > 20 {
+			if utf8.RuneCountInString(input) > 20 {
 				return fmt.Errorf("username too long (max 20 characters)")
 			}
+			if strings.ContainsFunc(input, unicode.IsSpace) {
+				return fmt.Errorf("username cannot contain spaces")
+			}
 			return nil
 		},
 		Templates: &promptui.PromptTemplates{
@@ -95,23 +102,53 @@ func GetUserInput() (string, string, error) {
 
 	username, err := usernamePrompt.Run()
 	if err != nil {
-		return "", "", err
+		return "", "", "", err
+	}
+
+	passwordPrompt := promptui.Prompt{
+		Label: "Password (leave empty to join as guest)",
+		Mask:  '*',
+	}
+
+	password, err := passwordPrompt.Run()
+	if err != nil {
+		return "", "", "", err
+	}
+
+	liveRooms, err := FetchRooms(server)
+	if err != nil {
+		fmt.Println(utils.ColorWarning("⚠️  Could not load the room directory: " + err.Error()))
+	}
+
+	room, err := selectRoom(liveRooms)
+	if err != nil {
+		return "", "", "", err
 	}
 
+	return username, password, room, nil
+}
+
+// selectRoom offers the server's live rooms, the default rooms and a
+// "Create new room" entry, and returns the chosen room name
+func selectRoom(liveRooms []models.Room) (string, error) {
 	// Clean room selection without emojis that might cause encoding issues
-	rooms := []string{
-		"general",
-		"tech",
-		"gaming",
-		"books",
-		"music",
-		"random",
-		"Create new room",
+	var labels, names []string
+	for _, room := range liveRooms {
+		labels = append(labels, formatRoom(room))
+		names = append(names, room.Name)
+	}
+	for _, name := range defaultRooms {
+		if !containsRoom(liveRooms, name) {
+			labels = append(labels, name)
+			names = append(names, name)
+		}
 	}
+	labels = append(labels, "Create new room")
 
 	roomPrompt := promptui.Select{
 		Label: "Select a chat room",
-		Items: rooms,
+		Items: labels,
+		Size:  10,
 		Templates: &promptui.SelectTemplates{
 			Label:    "{{ . | cyan | bold }}",
 			Active:   "▶ {{ . | green | bold }}",
@@ -120,22 +157,84 @@ func GetUserInput() (string, string, error) {
 		},
 	}
 
-	_, selectedRoom, err := roomPrompt.Run()
+	index, _, err := roomPrompt.Run()
+	if err != nil {
+		return "", err
+	}
+
+	if index == len(names) {
+		return promptNewRoom()
+	}
+	return names[index], nil
+}
+
+// promptNewRoom asks for the name of a room to create
+func promptNewRoom() (string, error) {
+	roomPrompt := promptui.Prompt{
+		Label: "Enter new room name",
+		Validate: func(input string) error {
+			if len(input) < 1 {
+				return fmt.Errorf("room name cannot be empty")
+			}
+			if len(input) > 32 {
+				return fmt.Errorf("room name too long (max 32 characters)")
+			}
+			if strings.ContainsAny(input, " \t") {
+				return fmt.Errorf("room name cannot contain spaces")
+			}
+			return nil
+		},
+	}
+
+	return roomPrompt.Run()
+}
+
+// containsRoom reports whether a room name appears in a directory listing
+func containsRoom(rooms []models.Room, name string) bool {
+	for _, room := range rooms {
+		if room.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ShowAvailableRooms asks a server for its live rooms and prints them
+func ShowAvailableRooms(cfg *Config) error {
+	serverAddr, err := ShowConnectionMenu(cfg.DefaultAddr())
+	if err != nil {
+		return err
+	}
+
+	server, err := NewEndpoint(cfg, serverAddr)
 	if err != nil {
-		return "", "", err
+		return err
 	}
 
-	room := selectedRoom // No emoji prefix to remove
+	rooms, err := FetchRooms(server)
+	if err != nil {
+		fmt.Println(utils.ColorError("❌ Could not load rooms: " + err.Error()))
+	} else if len(rooms) == 0 {
+		fmt.Println(utils.ColorInfo("📋 No active rooms yet - join one to create it!"))
+	} else {
+		tableData := pterm.TableData{{"Room", "Online", "Topic"}}
+		for _, room := range rooms {
+			tableData = append(tableData, []string{
+				room.Name, fmt.Sprintf("%d", room.Members), room.Topic,
+			})
+		}
+		pterm.DefaultTable.WithHasHeader().WithData(tableData).Render()
+	}
 
-	// Handle custom room creation...
-	return username, room, nil
+	fmt.Println("Press Enter to continue...")
+	fmt.Scanln()
+	return nil
 }
 
-// ShowConnectionMenu displays connection options
-// ShowConnectionMenu displays connection options
-func ShowConnectionMenu() (string, error) {
+// ShowConnectionMenu displays connection options, offering defaultAddr first
+func ShowConnectionMenu(defaultAddr string) (string, error) {
 	options := []string{
-		"🏠 localhost:8080 (Local)",
+		fmt.Sprintf("🏠 %s (Default)", defaultAddr),
 		"🌐 LAN Server (Enter IP)",
 		"🔗 Custom server",
 	}
@@ -158,7 +257,7 @@ func ShowConnectionMenu() (string, error) {
 
 	switch index {
 	case 0:
-		return "localhost:8080", nil
+		return defaultAddr, nil
 	case 1:
 		return getLANServerAddress()
 	case 2:
@@ -234,6 +333,10 @@ func ShowHelp() {
   • /help - Show this help
   • /users - List online users
   • /clear - Clear the screen
+  • /join <room> - Join another room or switch to it
+  • /part [room] - Leave a room (default: the current one)
+  • /msg <user> <text> - Send a private message
+  • /rooms - List active rooms on the server
 
 🎨 FEATURES:
   • Real-time messaging
@@ -312,14 +415,12 @@ func ConfirmExit() bool {
 }
 
 // HandleMenuSelection handles the selected menu option
-func HandleMenuSelection(option *MenuOption) error {
+func HandleMenuSelection(cfg *Config, option *MenuOption) error {
 	switch option.Label {
 	case "🚀 Join Chat Room":
 		return nil // Will be handled in main client logic
 	case "📋 View Available Rooms":
-		fmt.Println(utils.ColorInfo("📋 Available rooms feature coming soon!"))
-		fmt.Println("Press Enter to continue...")
-		fmt.Scanln()
+		ShowAvailableRooms(cfg)
 		return fmt.Errorf("back_to_menu")
 	case "⚙️  Settings":
 		ShowSettings()
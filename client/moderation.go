@@ -0,0 +1,54 @@
+package client
+
+import (
+	"strings"
+	"terminal-chat/models"
+	"time"
+)
+
+// moderationUsage explains each moderation command
+var moderationUsage = map[string]string{
+	models.ModKick:   "/kick <user> [reason]",
+	models.ModBan:    "/ban <user> [-ip] [reason]",
+	models.ModUnban:  "/unban <user>",
+	models.ModMute:   "/mute <user> [duration] [reason]",
+	models.ModUnmute: "/unmute <user>",
+	models.ModOp:     "/op <user>",
+	models.ModDeop:   "/deop <user>",
+}
+
+// handleModerationCommand asks the server to apply a moderation action to
+// a user of the active room; the server decides whether the user may
+func (c *Client) handleModerationCommand(action, command string) {
+	fields, rest := splitCommand(command, 2)
+	if len(fields) < 2 {
+		c.showSystemMessage("Usage: " + moderationUsage[action])
+		return
+	}
+	room := c.activeRoom()
+	if room == "" {
+		c.showSystemMessage("You are not in any room. Use /join <room> to enter one.")
+		return
+	}
+
+	msg := models.NewMessage(models.MessageTypeModerate, c.username, "", room)
+	msg.Action = action
+	msg.User = fields[1]
+
+	switch action {
+	case models.ModBan:
+		if flag, reason, _ := strings.Cut(rest, " "); flag == "-ip" {
+			msg.BanIP = true
+			rest = strings.TrimSpace(reason)
+		}
+	case models.ModMute:
+		// A leading duration such as 15m sets the mute's length
+		first, reason, _ := strings.Cut(rest, " ")
+		if duration, err := time.ParseDuration(first); err == nil && duration > 0 {
+			msg.Duration = int64(duration / time.Second)
+			rest = strings.TrimSpace(reason)
+		}
+	}
+	msg.Content = rest
+	c.send(msg)
+}
@@ -0,0 +1,86 @@
+package client
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"terminal-chat/models"
+	"terminal-chat/utils"
+)
+
+// emojiAliases lets reactions be typed without an emoji keyboard
+var emojiAliases = map[string]string{
+	"+1":    "👍",
+	"-1":    "👎",
+	"heart": "❤️",
+	"laugh": "😂",
+	"tada":  "🎉",
+	"eyes":  "👀",
+	"fire":  "🔥",
+	"wow":   "😮",
+}
+
+// handleReactCommand toggles a reaction: /react [^N] <emoji>
+func (c *Client) handleReactCommand(command string) {
+	target, emoji, ok := c.targetMessage(command, false)
+	if !ok {
+		return
+	}
+	if emoji == "" {
+		c.showSystemMessage("Usage: /react [^N] <emoji>. Shortcuts: " + strings.Join(aliasNames(), ", "))
+		return
+	}
+	if alias, found := emojiAliases[strings.Trim(emoji, ":")]; found {
+		emoji = alias
+	}
+
+	msg := models.NewMessage(models.MessageTypeReaction, c.username, "", target.Room)
+	msg.Target = target.ID
+	msg.Emoji = emoji
+	c.send(msg)
+}
+
+// aliasNames lists the reaction shortcuts in a stable order
+func aliasNames() []string {
+	names := make([]string, 0, len(emojiAliases))
+	for name := range emojiAliases {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// ApplyReactions replaces the tally of the message a reaction targets
+func (ui *UI) ApplyReactions(event models.Message) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	for i := len(ui.messages) - 1; i >= 0; i-- {
+		if ui.messages[i].ID == event.Target {
+			ui.messages[i].Reactions = event.Reactions
+			ui.redrawMessage(i)
+			return
+		}
+	}
+}
+
+// formatReactions renders the tally row under a message, highlighting the
+// emoji the user picked, or "" when nobody has reacted
+func (ui *UI) formatReactions(msg models.Message) string {
+	if len(msg.Reactions) == 0 || msg.Deleted {
+		return ""
+	}
+
+	parts := make([]string, 0, len(msg.Reactions))
+	for _, reaction := range msg.Reactions {
+		count := utils.ColorWhite(reaction.Count)
+		if slices.Contains(reaction.Users, ui.username) {
+			count = utils.ColorGreen(reaction.Count)
+		}
+		parts = append(parts, fmt.Sprintf("%s %s", reaction.Emoji, count))
+	}
+
+	return fmt.Sprintf("%s│ %s %s %s",
+		utils.ColorCyan(""), strings.Repeat(" ", 8),
+		utils.ColorFaint("↳"), strings.Join(parts, "  "))
+}
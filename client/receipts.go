@@ -0,0 +1,135 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+	"terminal-chat/models"
+	"terminal-chat/utils"
+	"time"
+)
+
+// readDelay batches read reports, so a burst of messages costs one report
+const readDelay = 2 * time.Second
+
+// noteRead records that a room message was shown, and schedules a report
+// of how far the user has read
+func (c *Client) noteRead(msg *models.Message) {
+	if !msg.Sequenced() || msg.ID == "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if msg.Seq <= c.readUpTo[msg.Room] {
+		return
+	}
+	c.readUpTo[msg.Room] = msg.Seq
+	if c.readTimer == nil {
+		c.readTimer = time.AfterFunc(readDelay, c.reportRead)
+	}
+}
+
+// reportRead tells the server how far the user has read in each room that
+// moved on since the last report
+func (c *Client) reportRead() {
+	c.mu.Lock()
+	c.readTimer = nil
+	var reports []*models.Message
+	for room, seq := range c.readUpTo {
+		if seq > c.readSent[room] && c.joined(room) {
+			msg := models.NewMessage(models.MessageTypeRead, c.username, "", room)
+			msg.Seq = seq
+			reports = append(reports, msg)
+		}
+	}
+	c.mu.Unlock()
+
+	// Reports that cannot be sent now go out with the next one
+	for _, msg := range reports {
+		if c.sendLive(msg) {
+			c.mu.Lock()
+			c.readSent[msg.Room] = max(c.readSent[msg.Room], msg.Seq)
+			c.mu.Unlock()
+		}
+	}
+}
+
+// handleSeenCommand asks who has read a message: /seen [^N]
+func (c *Client) handleSeenCommand(command string) {
+	target, _, ok := c.targetMessage(command, false)
+	if !ok {
+		return
+	}
+
+	msg := models.NewMessage(models.MessageTypeReceipts, c.username, "", target.Room)
+	msg.Target = target.ID
+	msg.Seq = target.Seq
+	c.send(msg)
+}
+
+// ShowReceipts prints the server's answer to /seen
+func (ui *UI) ShowReceipts(receipts models.Message) {
+	seen := "nobody yet"
+	if len(receipts.ReadBy) > 0 {
+		seen = strings.Join(receipts.ReadBy, ", ")
+	}
+	content := "👀 Seen by " + seen
+	if len(receipts.Unread) > 0 {
+		content += " · not yet: " + strings.Join(receipts.Unread, ", ")
+	}
+	ui.showNotice(content)
+}
+
+// SetReadMark records how far the user had read a room before joining it.
+// Only the first mark of a join counts: rejoins after a reconnect bring
+// marks that already include this session's reading.
+func (ui *UI) SetReadMark(room string, seq uint64) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	if _, ok := ui.readMarks[room]; !ok {
+		ui.readMarks[room] = seq
+	}
+}
+
+// ClearReadMark forgets the read mark and divider of a room that was left
+func (ui *UI) ClearReadMark(room string) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	delete(ui.readMarks, room)
+	delete(ui.unreadFrom, room)
+}
+
+// placeDivider remembers the first message past a room's read mark, which
+// the "new messages" divider is drawn above
+func (ui *UI) placeDivider(msg models.Message) {
+	mark, ok := ui.readMarks[msg.Room]
+	if !ok || !msg.Sequenced() || msg.Seq <= mark {
+		return
+	}
+	if _, placed := ui.unreadFrom[msg.Room]; placed {
+		return
+	}
+	if msg.Username == ui.username {
+		// Writing in a room shows it was read; nothing to divide
+		ui.unreadFrom[msg.Room] = ""
+		return
+	}
+	ui.unreadFrom[msg.Room] = msg.ID
+}
+
+// startsUnread reports whether the divider goes above a message
+func (ui *UI) startsUnread(msg models.Message) bool {
+	return msg.ID != "" && ui.unreadFrom[msg.Room] == msg.ID
+}
+
+// formatDivider renders the "new messages" divider of a room
+func (ui *UI) formatDivider(room string) string {
+	label := " new messages "
+	if room != ui.room {
+		label = fmt.Sprintf(" new messages in #%s ", room)
+	}
+	side := strings.Repeat("─", max(0, (ui.terminalWidth-4-len(label))/2))
+	return fmt.Sprintf("%s│ %s", utils.ColorCyan(""), utils.ColorRed(side+label+side))
+}
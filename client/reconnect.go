@@ -0,0 +1,296 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/url"
+	"slices"
+	"terminal-chat/models"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Reconnect delays grow from reconnectBaseDelay up to reconnectMaxDelay
+	reconnectBaseDelay = 500 * time.Millisecond
+	reconnectMaxDelay  = 30 * time.Second
+
+	// maxOutbox caps how many messages are held while offline
+	maxOutbox = 100
+)
+
+// Connection states shown in the header
+const (
+	stateConnected    = "CONNECTED"
+	stateReconnecting = "RECONNECTING"
+	stateOffline      = "OFFLINE"
+)
+
+var (
+	errLoginRefused = errors.New("server refused login")
+	errNameTaken    = errors.New("your name was taken")
+	errOffline      = errors.New("not connected to the server")
+	errOutboxFull   = fmt.Errorf("%d messages are already waiting to be sent", maxOutbox)
+)
+
+// backoff returns how long to wait before a reconnect attempt. The delay
+// doubles per attempt up to reconnectMaxDelay and half of it is random, so
+// clients dropped by the same restart do not all come back at once.
+func backoff(attempt int) time.Duration {
+	delay := reconnectMaxDelay
+	if attempt < 16 {
+		delay = min(reconnectBaseDelay<<attempt, reconnectMaxDelay)
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// queueable reports whether a message is worth sending late. Room changes
+// and directory requests only make sense on a live connection.
+func queueable(msgType models.MessageType) bool {
+	switch msgType {
+	case models.MessageTypeChat, models.MessageTypeGIF, models.MessageTypeDirect:
+		return true
+	}
+	return false
+}
+
+// shouldReconnect reports whether a read error is worth reconnecting after;
+// a policy close means the server ended this session on purpose
+func shouldReconnect(err error) bool {
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) {
+		return closeErr.Code != websocket.ClosePolicyViolation
+	}
+	return true
+}
+
+// reconnect redials the server until it answers, refuses the session or the
+// user quits, and reports whether the client is connected again
+func (c *Client) reconnect() bool {
+	for attempt := 0; ; attempt++ {
+		delay := backoff(attempt)
+		c.setState(fmt.Sprintf("%s (retry %d in %s)", stateReconnecting, attempt+1, delay.Round(100*time.Millisecond)))
+
+		select {
+		case <-c.done:
+			return false
+		case <-time.After(delay):
+		}
+
+		err := c.connect()
+		if errors.Is(err, errLoginRefused) && c.login != nil {
+			if err = c.renewSession(); err == nil {
+				err = c.connect()
+			}
+		}
+		if err == nil {
+			return true
+		}
+		if errors.Is(err, errLoginRefused) || errors.Is(err, errNameTaken) {
+			c.setState(stateOffline)
+			c.showSystemMessage(fmt.Sprintf("Could not reconnect: %v. Type /quit to exit.", err))
+			return false
+		}
+	}
+}
+
+// renewSession logs in again for a registered user whose session the server
+// no longer knows, as after a restart or once the session expired. A server
+// out of reach is tried again later; one that refuses the login is final.
+func (c *Client) renewSession() error {
+	token, err := c.login()
+	var unreachable *url.Error
+	if errors.As(err, &unreachable) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", errLoginRefused, err)
+	}
+	c.token = token
+	return nil
+}
+
+// resume restores the session on a fresh connection: the rooms in c.rejoin
+// are rejoined from the last seq seen in each, then the messages queued
+// while offline are sent. Both are paced to the server's limits, so a long
+// list is not refused as a flood, and messages typed meanwhile queue behind
+// them. It stops once done or once the connection is replaced.
+func (c *Client) resume(conn *websocket.Conn, limits models.Limits) {
+	if c.rejoinRooms(conn, limits.Joins) {
+		c.flushOutbox(conn, limits)
+	}
+}
+
+// rejoinRooms sends a join for each room waiting in c.rejoin, and reports
+// whether all of them went out on conn
+func (c *Client) rejoinRooms(conn *websocket.Conn, limit models.Rate) bool {
+	joins := newPacer(limit, defaultLimits.Joins, time.Now())
+	for {
+		c.mu.Lock()
+		if c.conn != conn {
+			c.mu.Unlock()
+			return false
+		}
+		if len(c.rejoin) == 0 {
+			c.mu.Unlock()
+			return true
+		}
+		room := c.rejoin[0]
+		if !slices.Contains(c.rooms, room) {
+			// Left with /part since the drop
+			c.rejoin = c.rejoin[1:]
+			c.mu.Unlock()
+			continue
+		}
+		if delay := joins.wait(time.Now()); delay > 0 {
+			c.mu.Unlock()
+			if !c.pause(delay) {
+				return false
+			}
+			continue
+		}
+
+		join := models.NewMessage(models.MessageTypeJoinRoom, c.username, "", room)
+		join.Since = c.lastSeq[room]
+		err := c.writeOn(conn, join)
+		if err == nil {
+			c.rejoin = c.rejoin[1:]
+		}
+		c.mu.Unlock()
+
+		if err != nil {
+			return false
+		}
+	}
+}
+
+// flushOutbox sends the messages queued while offline, in the order they
+// were typed. It stops once the queue is empty or the connection is replaced.
+func (c *Client) flushOutbox(conn *websocket.Conn, limits models.Limits) {
+	now := time.Now()
+	messages := newPacer(limits.Messages, defaultLimits.Messages, now)
+	pacers := map[models.MessageType]*pacer{
+		models.MessageTypeChat:   messages,
+		models.MessageTypeDirect: messages,
+		models.MessageTypeGIF:    newPacer(limits.GIFs, defaultLimits.GIFs, now),
+	}
+
+	for {
+		c.mu.Lock()
+		if c.conn != conn || len(c.outbox) == 0 {
+			c.mu.Unlock()
+			return
+		}
+		msg := c.outbox[0]
+		if delay := pacers[msg.Type].wait(time.Now()); delay > 0 {
+			c.mu.Unlock()
+			if !c.pause(delay) {
+				return
+			}
+			continue
+		}
+
+		err := c.writeOn(conn, msg)
+		if err == nil {
+			c.outbox = c.outbox[1:]
+		}
+		state, queued := c.state, len(c.outbox)
+		c.mu.Unlock()
+
+		if err != nil {
+			return
+		}
+		c.ui.SetConnectionStatus(state, queued)
+	}
+}
+
+// writeOn writes msg on conn, closing it on failure so the reader notices
+// the dead connection and reconnects. The caller holds c.mu.
+func (c *Client) writeOn(conn *websocket.Conn, msg *models.Message) error {
+	conn.SetWriteDeadline(time.Now().Add(writeWait))
+	err := conn.WriteJSON(msg)
+	if err != nil {
+		conn.Close()
+		c.conn = nil
+	}
+	return err
+}
+
+// pause waits for delay and reports whether the user is still connected
+func (c *Client) pause(delay time.Duration) bool {
+	select {
+	case <-c.done:
+		return false
+	case <-time.After(delay):
+		return true
+	}
+}
+
+// defaultLimits are assumed for servers that do not announce their limits
+var defaultLimits = models.Limits{
+	Messages: models.Rate{PerSecond: 1, Burst: 8},
+	GIFs:     models.Rate{PerSecond: 0.2, Burst: 2},
+	Joins:    models.Rate{PerSecond: 0.5, Burst: 5},
+}
+
+// pacer spaces sends to stay within one of the server's token buckets. It
+// keeps a quarter of the bucket in reserve and refills a tenth slower than
+// the server, for sends made alongside and for frames that arrive closer
+// together than they left.
+type pacer struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newPacer creates a pacer for a server limit, or for fallback if the
+// server did not announce a usable one
+func newPacer(limit, fallback models.Rate, now time.Time) *pacer {
+	if limit.PerSecond <= 0 || limit.Burst < 1 {
+		limit = fallback
+	}
+	burst := float64(max(limit.Burst-max(limit.Burst/4, 1), 1))
+	return &pacer{rate: limit.PerSecond * 0.9, burst: burst, tokens: burst, last: now}
+}
+
+// wait spends a token and returns 0 if one is left, or else returns how
+// long until there is
+func (p *pacer) wait(now time.Time) time.Duration {
+	p.tokens = min(p.burst, p.tokens+now.Sub(p.last).Seconds()*p.rate)
+	p.last = now
+	if p.tokens >= 1 {
+		p.tokens--
+		return 0
+	}
+	return max(time.Duration(math.Ceil((1-p.tokens)/p.rate*float64(time.Second))), 1)
+}
+
+// setState records the connection state and refreshes the header
+func (c *Client) setState(state string) {
+	c.mu.Lock()
+	c.state = state
+	queued := len(c.outbox)
+	c.mu.Unlock()
+
+	if c.ui != nil {
+		c.ui.SetConnectionStatus(state, queued)
+	}
+}
+
+// readWelcome reads the frame the server opens every connection with. Its
+// Username is the name the server gave us, which differs from ours if that
+// was taken.
+func readWelcome(conn *websocket.Conn) (*models.Message, error) {
+	var msg models.Message
+	if err := conn.ReadJSON(&msg); err != nil {
+		return nil, err
+	}
+	if msg.Type != models.MessageTypeWelcome {
+		return nil, fmt.Errorf("expected a welcome from the server, got %q", msg.Type)
+	}
+	return &msg, nil
+}
@@ -0,0 +1,133 @@
+package client
+
+import (
+	"fmt"
+	"sync/atomic"
+	"terminal-chat/models"
+	"terminal-chat/server"
+	"testing"
+	"time"
+)
+
+func TestPacerKeepsWithinLimit(t *testing.T) {
+	limit := models.Rate{PerSecond: 1, Burst: 8}
+	now := time.Unix(0, 0)
+	p := newPacer(limit, defaultLimits.Messages, now)
+
+	// The server's bucket, fed every send the pacer allows
+	tokens := float64(limit.Burst)
+	last := now
+	for sent := 0; sent < 100; {
+		if delay := p.wait(now); delay > 0 {
+			now = now.Add(delay)
+			continue
+		}
+		tokens = min(float64(limit.Burst), tokens+now.Sub(last).Seconds()*limit.PerSecond)
+		last = now
+		if tokens < 1 {
+			t.Fatalf("send %d at %s would be over the limit", sent+1, now.Sub(time.Unix(0, 0)))
+		}
+		tokens--
+		sent++
+	}
+}
+
+func TestFullOutboxFlushesWithinLimits(t *testing.T) {
+	// Fast enough to finish quickly, but the outbox is still more than a burst
+	cfg := server.DefaultConfig()
+	cfg.FrameLimit = server.RateLimit{Rate: 1000, Burst: 150}
+	cfg.MessageLimit = server.RateLimit{Rate: 200, Burst: 40}
+	ep := newTestServer(t, cfg)
+	c := newTestClient(t, ep, "alice", "general")
+
+	// Typed while offline, as many as the outbox holds
+	for i := range maxOutbox {
+		c.deliver(models.NewMessage(models.MessageTypeChat, c.username, fmt.Sprintf("queued %d", i), "general"))
+	}
+	c.start(t)
+
+	waitFor(t, 5*time.Second, "the outbox to be acked", func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for _, out := range c.inflight {
+			if out.failed {
+				t.Fatalf("%s (%q) was refused", out.msg.ClientID, out.msg.Content)
+			}
+		}
+		return len(c.inflight) == 0
+	})
+	if c.noticed("Slow down") || c.noticed("muted") {
+		t.Error("the flush was taken for a flood")
+	}
+}
+
+func TestRejoinsMoreRoomsThanABurst(t *testing.T) {
+	cfg := server.DefaultConfig()
+	cfg.JoinLimit = server.RateLimit{Rate: 20, Burst: 5}
+	ep := newTestServer(t, cfg)
+	c := newTestClient(t, ep, "alice", "general")
+
+	// As after a drop, the session holds rooms to rejoin besides the dialed one
+	for i := range 8 {
+		c.rooms = append(c.rooms, fmt.Sprintf("room%d", i))
+	}
+	c.start(t)
+
+	waitFor(t, 5*time.Second, "every room to be rejoined", func() bool {
+		c.ui.mu.Lock()
+		defer c.ui.mu.Unlock()
+		return len(c.ui.users) == 9
+	})
+	c.mu.Lock()
+	rooms := len(c.rooms)
+	c.mu.Unlock()
+	if rooms != 9 || c.noticed("Slow down") || c.noticed("muted") {
+		t.Errorf("rejoining was taken for a flood; in %d rooms", rooms)
+	}
+}
+
+func TestReconnectLogsInAgainOnceTheSessionIsGone(t *testing.T) {
+	cfg := server.DefaultConfig()
+	cfg.SessionTTL = 200 * time.Millisecond
+	ep, accounts := newTestServerWithAccounts(t, cfg)
+	if err := accounts.Register("Carol", "password1"); err != nil {
+		t.Fatal(err)
+	}
+	var logins atomic.Int32
+	login := func() (string, error) {
+		logins.Add(1)
+		token, _, err := accounts.Login("Carol", "password1")
+		return token, err
+	}
+
+	token, err := login()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newClient(ep, "Carol", token, "general")
+	c.ui = NewUI("Carol", "general")
+	c.login = login
+	c.start(t)
+
+	// The session expires, as it would with a server restart, before the drop
+	time.Sleep(cfg.SessionTTL)
+	c.mu.Lock()
+	c.conn.Close()
+	c.mu.Unlock()
+
+	waitFor(t, 5*time.Second, "the client to reconnect", func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.state == stateConnected && logins.Load() == 2
+	})
+}
+
+func TestDisconnectTwice(t *testing.T) {
+	ep := newTestServer(t, server.DefaultConfig())
+	c := newTestClient(t, ep, "alice", "general")
+	c.start(t)
+
+	// Quitting while a reconnect gives up disconnects twice
+	c.disconnect()
+	c.disconnect()
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"strconv"
+	"strings"
+	"terminal-chat/models"
+)
+
+// parseRef reads a message reference: ^1 is the latest message in the
+// active room, ^2 the one before it, and so on
+func parseRef(arg string) (int, bool) {
+	digits, ok := strings.CutPrefix(arg, "^")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(digits)
+	if err != nil || n < 1 {
+		return 0, false
+	}
+	return n, true
+}
+
+// recentMessage returns the nth latest message of a room that the server
+// has confirmed, counting from 1. With own set only the user's messages
+// count; in a thread view only the thread's messages do. It takes ui.mu.
+func (ui *UI) recentMessage(room string, n int, own bool) (models.Message, bool) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	for i := len(ui.messages) - 1; i >= 0; i-- {
+		msg := ui.messages[i]
+		if msg.Room != room || msg.ID == "" || msg.Deleted || !ui.inView(msg) {
+			continue
+		}
+		if msg.Type != models.MessageTypeChat && msg.Type != models.MessageTypeGIF {
+			continue
+		}
+		if own && msg.Username != ui.username {
+			continue
+		}
+		n--
+		if n == 0 {
+			return msg, true
+		}
+	}
+	return models.Message{}, false
+}
+
+// targetMessage resolves the optional ^N argument of a command. Without one
+// it falls back to the latest message, or the user's latest when own is
+// set. It returns the target and the rest of the command line after the
+// reference.
+func (c *Client) targetMessage(command string, own bool) (models.Message, string, bool) {
+	room := c.activeRoom()
+	fields, rest := splitCommand(command, 2)
+
+	n := 1
+	if len(fields) == 2 {
+		if ref, ok := parseRef(fields[1]); ok {
+			n, own = ref, false
+		} else {
+			_, rest = splitCommand(command, 1)
+		}
+	}
+
+	target, ok := c.ui.recentMessage(room, n, own)
+	if !ok {
+		c.showSystemMessage("No such message in this room.")
+	}
+	return target, rest, ok
+}
@@ -0,0 +1,40 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"terminal-chat/models"
+	"time"
+)
+
+// defaultRooms are offered in the room picker even when nobody is in them yet
+var defaultRooms = []string{"general", "tech", "gaming", "books", "music", "random"}
+
+// FetchRooms asks the server for its live room directory
+func FetchRooms(server *Endpoint) ([]models.Room, error) {
+	resp, err := server.HTTPClient(5 * time.Second).Get(server.URL("/rooms"))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned %s", resp.Status)
+	}
+
+	var rooms []models.Room
+	if err := json.NewDecoder(resp.Body).Decode(&rooms); err != nil {
+		return nil, err
+	}
+	return rooms, nil
+}
+
+// formatRoom renders a directory entry as a single picker line
+func formatRoom(room models.Room) string {
+	line := fmt.Sprintf("%s (%d online)", room.Name, room.Members)
+	if room.Topic != "" {
+		line += " - " + room.Topic
+	}
+	return line
+}
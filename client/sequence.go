@@ -0,0 +1,109 @@
+package client
+
+import (
+	"terminal-chat/models"
+)
+
+// maxBackfill is the most missed messages asked for at once, matching the
+// server's own cap; larger gaps are filled with their newest messages
+const maxBackfill = 200
+
+// acceptSeq checks a room message against the sequence numbers seen so far.
+// It returns false for messages already shown, and asks the server for any
+// messages skipped between the last one seen and this one.
+func (c *Client) acceptSeq(msg *models.Message) bool {
+	if !msg.Sequenced() {
+		return true
+	}
+
+	c.mu.Lock()
+	last := c.lastSeq[msg.Room]
+	if missing := c.missing[msg.Room]; missing[msg.Seq] {
+		delete(missing, msg.Seq)
+		c.mu.Unlock()
+		return true
+	}
+	if msg.Seq <= last {
+		c.mu.Unlock()
+		return false
+	}
+
+	c.lastSeq[msg.Room] = msg.Seq
+	// Replayed history starts wherever the server's limit cut it, so only
+	// live traffic can reveal a gap
+	gap := !msg.History && last > 0 && msg.Seq > last+1
+	var since uint64
+	if gap {
+		since = c.markMissing(msg.Room, last, msg.Seq)
+	}
+	c.mu.Unlock()
+
+	if gap {
+		c.requestBackfill(msg.Room, since, msg.Seq-1)
+	}
+	return true
+}
+
+// syncHead lines up with the room's latest seq announced by our own join.
+// A head behind what we saw means the server lost its history, so counting
+// starts over; a head ahead means messages were missed while away.
+func (c *Client) syncHead(room string, head uint64) {
+	c.mu.Lock()
+	last := c.lastSeq[room]
+	gap := last > 0 && head > last
+	var since uint64
+	switch {
+	case head < last:
+		c.lastSeq[room] = head
+		delete(c.missing, room)
+	case gap:
+		since = c.markMissing(room, last, head+1)
+		c.lastSeq[room] = head
+	case last == 0:
+		c.lastSeq[room] = head
+	}
+	c.mu.Unlock()
+
+	if gap {
+		c.requestBackfill(room, since, head)
+	}
+}
+
+// markMissing records the seqs strictly between last and next as awaited,
+// keeping only the newest maxBackfill of them, and returns the seq the
+// backfill should start after. The caller holds c.mu.
+func (c *Client) markMissing(room string, last, next uint64) uint64 {
+	since := last
+	if next-last-1 > maxBackfill {
+		since = next - 1 - maxBackfill
+	}
+
+	missing := c.missing[room]
+	if missing == nil {
+		missing = make(map[uint64]bool)
+		c.missing[room] = missing
+	}
+	for seq := since + 1; seq < next; seq++ {
+		missing[seq] = true
+	}
+	return since
+}
+
+// forgetSeq drops the sequence and read state of a room that was left, so
+// a later join starts from the usual history
+func (c *Client) forgetSeq(room string) {
+	c.mu.Lock()
+	delete(c.lastSeq, room)
+	delete(c.missing, room)
+	delete(c.readUpTo, room)
+	delete(c.readSent, room)
+	c.mu.Unlock()
+}
+
+// requestBackfill asks the server to resend messages since < seq <= until
+func (c *Client) requestBackfill(room string, since, until uint64) {
+	msg := models.NewMessage(models.MessageTypeBackfill, c.username, "", room)
+	msg.Since = since
+	msg.Until = until
+	c.send(msg)
+}
@@ -0,0 +1,107 @@
+package client
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"terminal-chat/models"
+	"terminal-chat/server"
+	"testing"
+	"time"
+)
+
+// roomMessage is a live chat message of a room at seq
+func roomMessage(room string, seq uint64) *models.Message {
+	msg := models.NewMessage(models.MessageTypeChat, "bob", fmt.Sprintf("message %d", seq), room)
+	msg.ID, msg.Seq = fmt.Sprintf("id-%d", seq), seq
+	return msg
+}
+
+func TestAcceptSeqSkipsRepeatsAndTracksGaps(t *testing.T) {
+	c := newClient(&Endpoint{Addr: "127.0.0.1:0"}, "alice", "", "general")
+	c.ui = NewUI("alice", "general")
+
+	if !c.acceptSeq(roomMessage("general", 1)) {
+		t.Fatal("the first message was refused")
+	}
+	if c.acceptSeq(roomMessage("general", 1)) {
+		t.Error("a repeated message was accepted")
+	}
+
+	// Skipping ahead leaves the messages in between awaited
+	if !c.acceptSeq(roomMessage("general", 5)) {
+		t.Fatal("a message after a gap was refused")
+	}
+	if got := slices.Sorted(maps.Keys(c.missing["general"])); !slices.Equal(got, []uint64{2, 3, 4}) {
+		t.Fatalf("awaiting %v, want [2 3 4]", got)
+	}
+
+	// Backfilled messages fill the gap once each
+	backfill := roomMessage("general", 3)
+	backfill.History = true
+	if !c.acceptSeq(backfill) {
+		t.Error("a backfilled message was refused")
+	}
+	if c.acceptSeq(backfill) {
+		t.Error("a backfilled message was accepted twice")
+	}
+	if c.lastSeq["general"] != 5 {
+		t.Errorf("last seq is %d after backfill, want 5", c.lastSeq["general"])
+	}
+}
+
+func TestSyncHeadCountsAgainAfterServerRestart(t *testing.T) {
+	c := newClient(&Endpoint{Addr: "127.0.0.1:0"}, "alice", "", "general")
+	c.ui = NewUI("alice", "general")
+	c.acceptSeq(roomMessage("general", 40))
+
+	c.syncHead("general", 3)
+	if c.lastSeq["general"] != 3 {
+		t.Errorf("last seq is %d after a head of 3, want 3", c.lastSeq["general"])
+	}
+	if !c.acceptSeq(roomMessage("general", 4)) {
+		t.Error("the server's next message was refused")
+	}
+}
+
+func TestReconnectCatchesUpOnMissedMessages(t *testing.T) {
+	ep := newTestServer(t, server.DefaultConfig())
+	alice := newTestClient(t, ep, "alice", "general")
+	alice.start(t)
+	bob := newTestClient(t, ep, "bob", "general")
+	bob.start(t)
+
+	first := models.NewMessage(models.MessageTypeChat, bob.username, "before", "general")
+	bob.deliver(first)
+	waitFor(t, 2*time.Second, "alice to see bob's first message", func() bool { return alice.shown("before") == 1 })
+
+	// Drop alice's connection and talk while alice is away
+	alice.mu.Lock()
+	alice.conn.UnderlyingConn().Close()
+	alice.mu.Unlock()
+	for i := range 3 {
+		msg := models.NewMessage(models.MessageTypeChat, bob.username, fmt.Sprintf("missed %d", i), "general")
+		bob.deliver(msg)
+		waitDelivery(t, bob, msg.ClientID, deliverySent)
+	}
+
+	waitFor(t, 5*time.Second, "alice to catch up", func() bool {
+		return alice.shown("missed 0") == 1 && alice.shown("missed 1") == 1 && alice.shown("missed 2") == 1
+	})
+	if n := alice.shown("before"); n != 1 {
+		t.Errorf("the message from before the drop was shown %d times", n)
+	}
+}
+
+// shown counts the chat messages on screen with the given content
+func (c *Client) shown(content string) int {
+	c.ui.mu.Lock()
+	defer c.ui.mu.Unlock()
+	n := 0
+	for _, msg := range c.ui.messages {
+		if msg.Type == models.MessageTypeChat && msg.Content == content {
+			n++
+		}
+	}
+	return n
+}
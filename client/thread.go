@@ -0,0 +1,95 @@
+package client
+
+import (
+	"fmt"
+	"terminal-chat/models"
+	"terminal-chat/utils"
+)
+
+// maxQuoteLength matches the excerpt length the server quotes
+const maxQuoteLength = 60
+
+// handleReplyCommand answers a message: /reply [^N] <text>
+func (c *Client) handleReplyCommand(command string) {
+	target, text, ok := c.targetMessage(command, false)
+	if !ok {
+		return
+	}
+	if text == "" {
+		c.showSystemMessage("Usage: /reply [^N] <text>")
+		return
+	}
+
+	msg := models.NewMessage(models.MessageTypeChat, c.username, text, target.Room)
+	msg.ReplyTo = target.ID
+	// Shown locally until the server's copy arrives with its own quote
+	msg.Thread = target.ThreadRoot()
+	msg.Quote = &models.Quote{Username: target.Username, Content: target.Content}
+	c.deliver(msg)
+}
+
+// handleThreadCommand shows one thread on its own: /thread [^N]
+func (c *Client) handleThreadCommand(command string) {
+	target, _, ok := c.targetMessage(command, false)
+	if !ok {
+		return
+	}
+	c.ui.ShowThread(target.ThreadRoot())
+}
+
+// ShowThread narrows the chat area to the messages of one thread
+func (ui *UI) ShowThread(root string) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	ui.thread = root
+	ui.refreshHeader()
+	ui.repaintChat()
+}
+
+// ShowRoom leaves the thread view and shows the whole room again
+func (ui *UI) ShowRoom() {
+	ui.mu.Lock()
+	inThread := ui.thread != ""
+	if inThread {
+		ui.thread = ""
+		ui.refreshHeader()
+		ui.repaintChat()
+	}
+	ui.mu.Unlock()
+
+	if !inThread {
+		ui.showNotice("You are not viewing a thread.")
+	}
+}
+
+// inView reports whether a message belongs on screen. A thread view keeps
+// the thread's messages and local notices, and hides other room traffic.
+func (ui *UI) inView(msg models.Message) bool {
+	if ui.thread == "" {
+		return true
+	}
+	switch msg.Type {
+	case models.MessageTypeChat, models.MessageTypeGIF:
+		return msg.ID == ui.thread || msg.Thread == ui.thread
+	case models.MessageTypeSystem, models.MessageTypeError:
+		return true
+	}
+	return false
+}
+
+// formatQuote renders the excerpt shown above a reply, or "" for messages
+// that reply to nothing
+func (ui *UI) formatQuote(msg models.Message) string {
+	if msg.Quote == nil || msg.Deleted {
+		return ""
+	}
+
+	content := []rune(msg.Quote.Content)
+	if len(content) > maxQuoteLength {
+		content = append(content[:maxQuoteLength-1], '…')
+	}
+	return fmt.Sprintf("%s│ %s %s",
+		utils.ColorCyan(""), "         ",
+		utils.ColorFaint(fmt.Sprintf("╭ %s: %s", msg.Quote.Username, string(content))))
+}
@@ -0,0 +1,109 @@
+package client
+
+import (
+	"fmt"
+	"terminal-chat/models"
+	"terminal-chat/utils"
+	"time"
+)
+
+// showWelcome shows what the server greeted the first connection with: why
+// our name changed, if it did, and the message of the day
+func (c *Client) showWelcome() {
+	if c.welcome.Content != "" {
+		c.showSystemMessage(c.welcome.Content)
+	}
+	if c.welcome.MOTD != "" {
+		c.showSystemMessage("📣 " + c.welcome.MOTD)
+	}
+}
+
+// handleTopicCommand shows the active room's topic, or asks the server to
+// change it: /topic [text], with "-" to clear it
+func (c *Client) handleTopicCommand(command string) {
+	c.setRoomField(models.RoomFieldTopic, command)
+}
+
+// handleDescribeCommand asks the server to change the active room's
+// description: /describe <text>, with "-" to clear it
+func (c *Client) handleDescribeCommand(command string) {
+	c.setRoomField(models.RoomFieldDescription, command)
+}
+
+// setRoomField sends a topic or description change for the active room;
+// the server decides whether the user may make it
+func (c *Client) setRoomField(field, command string) {
+	room := c.activeRoom()
+	if room == "" {
+		c.showSystemMessage("You are not in any room. Use /join <room> to enter one.")
+		return
+	}
+
+	_, text := splitCommand(command, 1)
+	if text == "" {
+		if field == models.RoomFieldTopic {
+			c.ui.ShowRoomInfo(room)
+		} else {
+			c.showSystemMessage("Usage: /describe <text>, or /describe - to clear it")
+		}
+		return
+	}
+	if text == "-" {
+		text = ""
+	}
+
+	msg := models.NewMessage(models.MessageTypeTopic, c.username, text, room)
+	msg.Action = field
+	c.send(msg)
+}
+
+// SetRoomInfo records what a room is about, updating the header when the
+// room is on screen
+func (ui *UI) SetRoomInfo(info models.Room) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	ui.roomInfo[info.Name] = info
+	if info.Name == ui.room {
+		ui.refreshHeader()
+	}
+}
+
+// ShowRoomInfo prints everything known about a room in the chat area
+func (ui *UI) ShowRoomInfo(room string) {
+	ui.mu.Lock()
+	info := ui.roomInfo[room]
+	ui.mu.Unlock()
+
+	lines := []string{fmt.Sprintf("#%s", room)}
+	if info.Topic != "" {
+		lines = append(lines, fmt.Sprintf("  Topic: %s (set by %s)", info.Topic, info.TopicBy))
+	} else {
+		lines = append(lines, "  No topic is set.")
+	}
+	if info.Description != "" {
+		lines = append(lines, "  "+info.Description)
+	}
+	switch {
+	case info.CreatedAt.IsZero() && info.CreatedBy != "":
+		lines = append(lines, "  Owned by "+info.CreatedBy)
+	case info.CreatedBy != "":
+		lines = append(lines, fmt.Sprintf("  Created by %s on %s",
+			info.CreatedBy, info.CreatedAt.Local().Format(time.DateOnly)))
+	}
+	for _, line := range lines {
+		ui.showNotice(line)
+	}
+}
+
+// topicLine is the header line under the room name
+func (ui *UI) topicLine() string {
+	topic := ui.roomInfo[ui.room].Topic
+	if topic == "" {
+		return utils.ColorFaint("No topic set")
+	}
+	if width := ui.terminalWidth - 10; len([]rune(topic)) > width {
+		topic = string([]rune(topic)[:width-1]) + "…"
+	}
+	return utils.ColorYellow(topic)
+}
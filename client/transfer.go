@@ -0,0 +1,371 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"terminal-chat/models"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// upload is a file the user offered, waiting for the server to accept it
+type upload struct {
+	path string
+	file models.File
+}
+
+// download is a file the user asked for. It is only touched by the reader
+// goroutine once the server has started sending it.
+type download struct {
+	clientID string // Correlation ID of the request, echoed on errors
+	dest     string // Where the user asked to save it, "" for the working directory
+
+	file     models.File
+	path     string   // Final location, set once the server starts sending
+	out      *os.File // Partial file, renamed to path once verified
+	hash     hash.Hash
+	received int64
+}
+
+// handleSendCommand offers a file to the active room: /send <path>. The
+// chunks follow once the server accepts the offer.
+func (c *Client) handleSendCommand(command string) {
+	_, path := splitCommand(command, 1)
+	if path == "" {
+		c.showSystemMessage("Usage: /send <path>")
+		return
+	}
+	room := c.activeRoom()
+	if room == "" {
+		c.showSystemMessage("You are not in any room. Use /join <room> to enter one.")
+		return
+	}
+
+	info, err := os.Stat(path)
+	if err == nil && !info.Mode().IsRegular() {
+		err = fmt.Errorf("not a regular file")
+	} else if err == nil && info.Size() == 0 {
+		err = fmt.Errorf("the file is empty")
+	}
+	var sum string
+	if err == nil {
+		sum, err = fileChecksum(path)
+	}
+	if err != nil {
+		c.showSystemMessage(fmt.Sprintf("Cannot send %s: %v", path, err))
+		return
+	}
+
+	msg := models.NewMessage(models.MessageTypeFileOffer, c.username, "", room)
+	msg.File = &models.File{Name: filepath.Base(path), Size: info.Size(), SHA256: sum}
+
+	c.mu.Lock()
+	c.nextID++
+	msg.ClientID = fmt.Sprintf("%s-%d", c.sessionID, c.nextID)
+	c.uploads[msg.ClientID] = &upload{path: path, file: *msg.File}
+	c.mu.Unlock()
+
+	if !c.send(msg) {
+		c.mu.Lock()
+		delete(c.uploads, msg.ClientID)
+		c.mu.Unlock()
+		return
+	}
+	c.showSystemMessage(fmt.Sprintf("⬆️ Offering %s (%s) to #%s…", msg.File.Name, formatSize(msg.File.Size), room))
+}
+
+// startUpload streams an accepted offer's chunks in the background
+func (c *Client) startUpload(msg *models.Message) {
+	c.mu.Lock()
+	up := c.uploads[msg.ClientID]
+	delete(c.uploads, msg.ClientID)
+	conn := c.conn
+	c.mu.Unlock()
+
+	if up == nil || msg.File == nil || conn == nil {
+		return
+	}
+	go c.streamUpload(conn, up, msg.File.ID)
+}
+
+// streamUpload writes a file as binary frames on conn, interleaved with
+// whatever else the user sends. It gives up if the connection is replaced,
+// since the server forgets unfinished uploads with the connection.
+func (c *Client) streamUpload(conn *websocket.Conn, up *upload, id string) {
+	defer c.ui.SetProgress("")
+
+	in, err := os.Open(up.path)
+	if err != nil {
+		c.showSystemMessage(fmt.Sprintf("Cannot send %s: %v", up.file.Name, err))
+		return
+	}
+	defer in.Close()
+
+	frame := make([]byte, models.FileIDSize+models.FileChunkSize)
+	copy(frame, id)
+	var sent int64
+	for sent < up.file.Size {
+		size := min(int64(models.FileChunkSize), up.file.Size-sent)
+		chunk := frame[:models.FileIDSize+size]
+		if _, err := io.ReadFull(in, chunk[models.FileIDSize:]); err != nil {
+			c.showSystemMessage(fmt.Sprintf("⚠️ Sending %s stopped: %v", up.file.Name, err))
+			return
+		}
+
+		c.mu.Lock()
+		if c.conn == conn {
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			err = conn.WriteMessage(websocket.BinaryMessage, chunk)
+		} else {
+			err = errOffline
+		}
+		c.mu.Unlock()
+		if err != nil {
+			c.showSystemMessage(fmt.Sprintf("⚠️ Sending %s was interrupted; /send it again once reconnected.", up.file.Name))
+			return
+		}
+
+		sent += size
+		c.ui.SetProgress(progressText("⬆️", up.file.Name, sent, up.file.Size))
+	}
+}
+
+// handleDownloadCommand fetches a shared file: /download <id> [dest]
+func (c *Client) handleDownloadCommand(command string) {
+	fields, dest := splitCommand(command, 2)
+	if len(fields) < 2 {
+		c.showSystemMessage("Usage: /download <id> [destination]")
+		return
+	}
+	id := strings.ToUpper(fields[1])
+
+	msg := models.NewMessage(models.MessageTypeFileRequest, c.username, "", "")
+	msg.Target = id
+
+	c.mu.Lock()
+	if c.downloads[id] != nil {
+		c.mu.Unlock()
+		c.showSystemMessage(fmt.Sprintf("Already downloading %s.", id))
+		return
+	}
+	c.nextID++
+	msg.ClientID = fmt.Sprintf("%s-%d", c.sessionID, c.nextID)
+	c.downloads[id] = &download{clientID: msg.ClientID, dest: dest}
+	c.mu.Unlock()
+
+	if !c.send(msg) {
+		c.mu.Lock()
+		delete(c.downloads, id)
+		c.mu.Unlock()
+	}
+}
+
+// beginDownload opens a partial file for a download the server started
+func (c *Client) beginDownload(file *models.File) {
+	c.mu.Lock()
+	d := c.downloads[file.ID]
+	c.mu.Unlock()
+	if d == nil || d.out != nil {
+		return
+	}
+
+	path, err := downloadPath(d.dest, file.Name)
+	var out *os.File
+	if err == nil {
+		out, err = os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.part")
+	}
+	if err != nil {
+		c.endDownload(file.ID)
+		c.showSystemMessage(fmt.Sprintf("Cannot download %s: %v", file.Name, err))
+		return
+	}
+
+	d.file, d.path, d.out, d.hash = *file, path, out, sha256.New()
+	c.showSystemMessage(fmt.Sprintf("⬇️ Downloading %s (%s) to %s…", file.Name, formatSize(file.Size), path))
+}
+
+// receiveChunk writes a binary frame to the download it belongs to and
+// saves the file once the last chunk is in and its checksum matches
+func (c *Client) receiveChunk(frame []byte) {
+	if len(frame) < models.FileIDSize {
+		return
+	}
+	id, chunk := string(frame[:models.FileIDSize]), frame[models.FileIDSize:]
+
+	c.mu.Lock()
+	d := c.downloads[id]
+	c.mu.Unlock()
+	if d == nil || d.out == nil {
+		return
+	}
+
+	if d.received+int64(len(chunk)) > d.file.Size {
+		c.endDownload(id)
+		c.showSystemMessage(fmt.Sprintf("❌ %s was larger than announced and was discarded.", d.file.Name))
+		return
+	}
+	if _, err := d.out.Write(chunk); err != nil {
+		c.endDownload(id)
+		c.showSystemMessage(fmt.Sprintf("❌ Cannot save %s: %v", d.file.Name, err))
+		return
+	}
+	d.hash.Write(chunk)
+	d.received += int64(len(chunk))
+	c.ui.SetProgress(progressText("⬇️", d.file.Name, d.received, d.file.Size))
+	if d.received < d.file.Size {
+		return
+	}
+
+	c.mu.Lock()
+	delete(c.downloads, id)
+	c.mu.Unlock()
+	c.ui.SetProgress("")
+
+	partial := d.out.Name()
+	err := d.out.Close()
+	if err == nil && hex.EncodeToString(d.hash.Sum(nil)) != d.file.SHA256 {
+		err = fmt.Errorf("its checksum does not match")
+	}
+	if err == nil {
+		err = os.Rename(partial, d.path)
+	}
+	if err != nil {
+		os.Remove(partial)
+		c.showSystemMessage(fmt.Sprintf("❌ %s was discarded: %v", d.file.Name, err))
+		return
+	}
+	c.showSystemMessage(fmt.Sprintf("✅ Saved %s to %s (checksum verified)", d.file.Name, d.path))
+}
+
+// cancelTransfer drops the upload or download a server error refers to,
+// by the client ID of its request or the file ID it names
+func (c *Client) cancelTransfer(msg *models.Message) {
+	c.mu.Lock()
+	delete(c.uploads, msg.ClientID)
+	var ids []string
+	for id, d := range c.downloads {
+		if (msg.ClientID != "" && d.clientID == msg.ClientID) || (msg.Target != "" && id == msg.Target) {
+			ids = append(ids, id)
+		}
+	}
+	c.mu.Unlock()
+
+	for _, id := range ids {
+		c.endDownload(id)
+	}
+}
+
+// abortTransfers forgets every transfer when the connection drops; the
+// server has already forgotten them
+func (c *Client) abortTransfers() {
+	c.mu.Lock()
+	clear(c.uploads)
+	var names []string
+	for id, d := range c.downloads {
+		if d.out != nil {
+			names = append(names, d.file.Name)
+		}
+		c.discardDownload(id)
+	}
+	c.mu.Unlock()
+
+	c.ui.SetProgress("")
+	for _, name := range names {
+		c.showSystemMessage(fmt.Sprintf("⚠️ Downloading %s was interrupted; /download it again once reconnected.", name))
+	}
+}
+
+// endDownload forgets a download, throwing away what arrived of it
+func (c *Client) endDownload(id string) {
+	c.mu.Lock()
+	c.discardDownload(id)
+	c.mu.Unlock()
+	c.ui.SetProgress("")
+}
+
+// discardDownload removes a download and its partial file. Callers hold c.mu.
+func (c *Client) discardDownload(id string) {
+	if d := c.downloads[id]; d != nil && d.out != nil {
+		d.out.Close()
+		os.Remove(d.out.Name())
+	}
+	delete(c.downloads, id)
+}
+
+// downloadPath picks where a download is saved: dest itself, or a file
+// named like the shared one inside dest or the working directory. Existing
+// files are never overwritten.
+func downloadPath(dest, name string) (string, error) {
+	name = filepath.Base(name)
+	if name == "." || name == ".." || name == string(filepath.Separator) {
+		name = "download"
+	}
+
+	path := name
+	if dest != "" {
+		path = dest
+		if info, err := os.Stat(dest); err == nil && info.IsDir() {
+			path = filepath.Join(dest, name)
+		}
+	}
+	if _, err := os.Stat(path); err == nil {
+		return "", fmt.Errorf("%s already exists", path)
+	}
+	return path, nil
+}
+
+// fileChecksum returns the hex SHA-256 digest of a file
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// formatSize renders a byte count for people: 512 B, 4.5 KB, 10.0 MB
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, suffix := float64(n)/unit, "KB"
+	for _, next := range []string{"MB", "GB"} {
+		if value < unit {
+			break
+		}
+		value, suffix = value/unit, next
+	}
+	return fmt.Sprintf("%.1f %s", value, suffix)
+}
+
+// progressText draws a transfer's progress bar: "⬆️ notes.txt ▓▓▓░░░ 50% 1.0 MB / 2.0 MB"
+func progressText(arrow, name string, done, total int64) string {
+	const width = 20
+	filled := int(done * width / max(total, 1))
+	return fmt.Sprintf("%s %s %s%s %d%% %s / %s", arrow, name,
+		strings.Repeat("▓", filled), strings.Repeat("░", width-filled),
+		done*100/max(total, 1), formatSize(done), formatSize(total))
+}
+
+// SetProgress shows a transfer's progress on the typing line, or clears it
+func (ui *UI) SetProgress(text string) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	ui.progress = text
+	ui.showTypingLine()
+}
@@ -0,0 +1,92 @@
+package client
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"terminal-chat/models"
+	"terminal-chat/server"
+	"testing"
+	"time"
+)
+
+// sharedFile returns the newest file shared in the client's rooms
+func (c *Client) sharedFile() *models.File {
+	c.ui.mu.Lock()
+	defer c.ui.mu.Unlock()
+	for i := len(c.ui.messages) - 1; i >= 0; i-- {
+		if msg := c.ui.messages[i]; msg.Type == models.MessageTypeFile && msg.File != nil {
+			return msg.File
+		}
+	}
+	return nil
+}
+
+// noticed reports whether a local notice containing text was shown
+func (c *Client) noticed(text string) bool {
+	c.ui.mu.Lock()
+	defer c.ui.mu.Unlock()
+	for _, msg := range c.ui.messages {
+		if msg.Type == models.MessageTypeSystem && strings.Contains(msg.Content, text) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSendAndDownload(t *testing.T) {
+	ep := newTestServer(t, server.DefaultConfig())
+	alice := newTestClient(t, ep, "alice", "general")
+	alice.start(t)
+	bob := newTestClient(t, ep, "bob", "general")
+	bob.start(t)
+
+	dir := t.TempDir()
+	data := bytes.Repeat([]byte("a line of the log\n"), 5000)
+	src := filepath.Join(dir, "app.log")
+	if err := os.WriteFile(src, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	alice.handleSendCommand("/send " + src)
+	var file *models.File
+	waitFor(t, 5*time.Second, "bob to see the file", func() bool {
+		file = bob.sharedFile()
+		return file != nil
+	})
+
+	dest := filepath.Join(dir, "copy.log")
+	bob.handleDownloadCommand("/download " + file.ID + " " + dest)
+	waitFor(t, 5*time.Second, "the download to be saved", func() bool { return bob.noticed("checksum verified") })
+
+	got, err := os.ReadFile(dest)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("the saved file differs from the one sent (%v)", err)
+	}
+}
+
+func TestCorruptDownloadIsDiscarded(t *testing.T) {
+	c := newClient(&Endpoint{Addr: "127.0.0.1:0"}, "bob", "", "general")
+	c.ui = NewUI("bob", "general")
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "notes.txt")
+
+	sum := sha256.Sum256([]byte("what was announced"))
+	file := &models.File{ID: "ABCDEFGHJK", Name: "notes.txt", Size: 18, SHA256: hex.EncodeToString(sum[:])}
+	c.downloads[file.ID] = &download{dest: dest}
+	c.beginDownload(file)
+	c.receiveChunk(append([]byte(file.ID), "what arrived here!"...))
+
+	if !c.noticed("checksum does not match") {
+		t.Error("a corrupt download was not reported")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("a corrupt download left %s behind", entries[0].Name())
+	}
+	if len(c.downloads) != 0 {
+		t.Error("a corrupt download is still tracked")
+	}
+}
@@ -0,0 +1,139 @@
+package client
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"terminal-chat/models"
+	"terminal-chat/utils"
+	"time"
+)
+
+const (
+	typingRefresh = 3 * time.Second // How often a start is resent while typing goes on
+	typingPause   = 5 * time.Second // Idle time after which the user counts as stopped
+)
+
+// noteTyping is called after every keystroke with the text in the input
+// bar. It announces typing in the active room at most once per
+// typingRefresh, and stops it once the line is empty, a command, or idle.
+func (c *Client) noteTyping(text string) {
+	if text == "" || strings.HasPrefix(text, "/") {
+		c.stopTyping()
+		return
+	}
+	room := c.activeRoom()
+
+	c.mu.Lock()
+	if c.typingTimer != nil {
+		c.typingTimer.Stop()
+	}
+	c.typingTimer = time.AfterFunc(typingPause, c.stopTyping)
+
+	previous := c.typingRoom
+	announce := c.conn != nil && room != "" &&
+		(room != previous || time.Since(c.typingSent) >= typingRefresh)
+	if announce {
+		c.typingRoom = room
+		c.typingSent = time.Now()
+	}
+	c.mu.Unlock()
+
+	if !announce {
+		return
+	}
+	if previous != "" && previous != room {
+		c.sendLive(models.NewMessage(models.MessageTypeTypingStop, c.username, "", previous))
+	}
+	c.sendLive(models.NewMessage(models.MessageTypeTypingStart, c.username, "", room))
+}
+
+// stopTyping tells the room the user stopped typing, if it was told otherwise
+func (c *Client) stopTyping() {
+	c.mu.Lock()
+	room := c.typingRoom
+	c.typingRoom = ""
+	if c.typingTimer != nil {
+		c.typingTimer.Stop()
+		c.typingTimer = nil
+	}
+	c.mu.Unlock()
+
+	if room != "" {
+		c.sendLive(models.NewMessage(models.MessageTypeTypingStop, c.username, "", room))
+	}
+}
+
+// sendLive writes a message that only matters while connected, such as a
+// typing signal, dropping it instead of queueing it while the connection
+// is down. It reports whether the message was sent.
+func (c *Client) sendLive(msg *models.Message) bool {
+	c.mu.Lock()
+	connected := c.conn != nil
+	c.mu.Unlock()
+
+	return connected && c.send(msg)
+}
+
+// SetTyping adds a user to or removes them from the typing line of a room
+func (ui *UI) SetTyping(room, username string, typing bool) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	names := slices.DeleteFunc(ui.typing[room], func(name string) bool {
+		return name == username
+	})
+	if typing {
+		names = append(names, username)
+	}
+	if len(names) == 0 {
+		delete(ui.typing, room)
+	} else {
+		ui.typing[room] = names
+	}
+
+	if room == ui.room {
+		ui.showTypingLine()
+	}
+}
+
+// ClearTyping empties the typing line of a room, or of every room when
+// room is "", for when the signals can no longer be trusted
+func (ui *UI) ClearTyping(room string) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	if room == "" {
+		clear(ui.typing)
+	} else {
+		delete(ui.typing, room)
+	}
+	ui.showTypingLine()
+}
+
+// showTypingLine draws who is typing in the active room just above the
+// input bar, or how a file transfer is going while one runs
+func (ui *UI) showTypingLine() {
+	fmt.Print("\033[s") // Save cursor position
+	fmt.Printf("\033[%d;1H\033[K", ui.terminalHeight-3)
+	if ui.progress != "" {
+		fmt.Printf("  %s", utils.ColorCyan(ui.progress))
+	} else if text := typingText(ui.typing[ui.room]); text != "" {
+		fmt.Printf("  %s", utils.ColorFaint(text))
+	}
+	fmt.Print("\033[u") // Restore cursor position
+}
+
+// typingText phrases the typing line: "alice, bob are typing…"
+func typingText(names []string) string {
+	switch len(names) {
+	case 0:
+		return ""
+	case 1:
+		return names[0] + " is typing…"
+	case 2, 3:
+		return strings.Join(names, ", ") + " are typing…"
+	default:
+		return fmt.Sprintf("%s and %d others are typing…", strings.Join(names[:2], ", "), len(names)-2)
+	}
+}
@@ -2,7 +2,9 @@ package client
 
 import (
 	"fmt"
+	"slices"
 	"strings"
+	"sync"
 	"terminal-chat/gifs"
 	"terminal-chat/models"
 	"terminal-chat/utils"
@@ -13,16 +15,31 @@ import (
 
 // Add GIF animation tracking to UI struct
 type UI struct {
+	// mu guards the fields below and the terminal, which the input, reader
+	// and animation goroutines all draw on. Exported methods take it;
+	// unexported ones expect it held unless they say otherwise.
+	mu sync.Mutex
+
 	username       string
 	room           string
+	rooms          []string
+	status         string // Connection state shown in the header
+	queued         int    // Messages waiting for the connection to come back
 	messages       []models.Message
-	users          []string
+	users          map[string][]models.User // Members per room
 	colorMap       map[string]func(...interface{}) string
 	messageArea    *pterm.AreaPrinter
 	chatHeight     int
 	terminalWidth  int
 	terminalHeight int
 	activeGIFs     map[string]*GIFAnimation // Track active GIF animations
+	delivery       map[string]deliveryState // Delivery state of own messages, by client ID
+	thread         string                   // Root ID of the thread on screen, "" for the whole room
+	typing         map[string][]string      // Users typing per room, in the order they started
+	readMarks      map[string]uint64        // Seq each room was read up to before joining
+	unreadFrom     map[string]string        // ID of the first new message per room, "" for none
+	roomInfo       map[string]models.Room   // Topic and description per room
+	progress       string                   // Transfer progress shown on the typing line, "" for none
 }
 
 // GIFAnimation tracks an active GIF animation
@@ -30,7 +47,7 @@ type GIFAnimation struct {
 	GIF          gifs.AnimatedGIF
 	CurrentFrame int
 	LastUpdate   time.Time
-	Position     int // Line position in chat
+	Position     int // Index of the message in the chat history
 	Username     string
 	Timestamp    string
 }
@@ -46,35 +63,49 @@ func NewUI(username, room string) *UI {
 	return &UI{
 		username:       username,
 		room:           room,
+		rooms:          []string{room},
+		status:         stateConnected,
 		messages:       make([]models.Message, 0),
-		users:          make([]string, 0),
+		users:          make(map[string][]models.User),
 		colorMap:       make(map[string]func(...interface{}) string),
-		chatHeight:     height - 8,
+		chatHeight:     height - 9,
 		terminalWidth:  width,
 		terminalHeight: height,
 		activeGIFs:     make(map[string]*GIFAnimation), // Initialize GIF tracking
+		delivery:       make(map[string]deliveryState),
+		typing:         make(map[string][]string),
+		readMarks:      make(map[string]uint64),
+		unreadFrom:     make(map[string]string),
+		roomInfo:       make(map[string]models.Room),
 	}
 }
 
 // InitScreen initializes the chat screen with fixed input bar
 func (ui *UI) InitScreen() {
+	ui.mu.Lock()
 	utils.ClearScreen()
 	ui.showChatHeader()
 	ui.showInputBar()
+	ui.mu.Unlock()
 	ui.positionCursorForChat()
 }
 
 // showChatHeader displays the chat header
 func (ui *UI) showChatHeader() {
 	// Chat header
+	title := fmt.Sprintf("💬 CHAT ROOM: %s", strings.ToUpper(ui.room))
+	if ui.thread != "" {
+		title = fmt.Sprintf("🧵 THREAD IN %s - /back to return", strings.ToUpper(ui.room))
+	}
 	headerPanel := pterm.DefaultPanel.
 		WithPanels([][]pterm.Panel{
 			{
-				{Data: pterm.DefaultCenter.Sprint(fmt.Sprintf("💬 CHAT ROOM: %s", strings.ToUpper(ui.room)))},
+				{Data: pterm.DefaultCenter.Sprint(title + "\n" + ui.topicLine())},
 			},
 			{
 				{Data: fmt.Sprintf("User: %s", utils.ColorGreen(ui.username))},
-				{Data: fmt.Sprintf("Status: %s", utils.ColorSuccess("CONNECTED"))},
+				{Data: fmt.Sprintf("Status: %s", ui.statusText())},
+				{Data: fmt.Sprintf("Rooms: %s", utils.ColorCyan(strings.Join(ui.rooms, ", ")))},
 			},
 		}).
 		WithPadding(1)
@@ -94,6 +125,55 @@ func (ui *UI) showChatHeader() {
 		utils.ColorCyan(""))
 }
 
+// refreshHeader redraws the header in place without touching the chat area
+func (ui *UI) refreshHeader() {
+	fmt.Print("\033[s") // Save cursor position
+	for line := 1; line < chatTop; line++ {
+		fmt.Printf("\033[%d;1H\033[K", line)
+	}
+	fmt.Print("\033[1;1H")
+	ui.showChatHeader()
+	fmt.Print("\033[u") // Restore cursor position
+}
+
+// statusText colours the connection state and notes any queued messages
+func (ui *UI) statusText() string {
+	var status string
+	switch {
+	case ui.status == stateConnected:
+		status = utils.ColorSuccess(ui.status)
+	case strings.HasPrefix(ui.status, stateReconnecting):
+		status = utils.ColorWarning(ui.status)
+	default:
+		status = utils.ColorError(ui.status)
+	}
+	if ui.queued > 0 {
+		status += utils.ColorYellow(fmt.Sprintf(" · %d queued", ui.queued))
+	}
+	return status
+}
+
+// SetConnectionStatus updates the connection state in the header
+func (ui *UI) SetConnectionStatus(status string, queued int) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	ui.status = status
+	ui.queued = queued
+	ui.refreshHeader()
+}
+
+// SetRooms updates the active room and the joined room list in the header
+func (ui *UI) SetRooms(active string, rooms []string) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	ui.room = active
+	ui.rooms = append([]string(nil), rooms...)
+	ui.refreshHeader()
+	ui.showTypingLine()
+}
+
 // showInputBar displays the fixed input bar at the bottom
 func (ui *UI) showInputBar() {
 	// Move cursor to bottom of terminal
@@ -115,55 +195,206 @@ func (ui *UI) showInputBar() {
 		utils.ColorWhite(""),
 		strings.Repeat(" ", ui.terminalWidth-6),
 		utils.ColorMagenta(""))
+
+	ui.showTypingLine()
 }
 
-// Update DisplayMessage to handle GIF messages properly
+// DisplayMessage appends a message to the chat area, scrolling when it is full
 func (ui *UI) DisplayMessage(msg models.Message) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	if ui.formatMessage(msg) == "" {
+		return
+	}
+	ui.placeDivider(msg)
 	ui.messages = append(ui.messages, msg)
 
+	if msg.Type == models.MessageTypeGIF && !msg.History {
+		if gif, exists := gifs.GetGIF(msg.GIFName); exists {
+			ui.startGIFAnimation(gif, msg.Username, ui.formatTimestamp(msg), len(ui.messages)-1)
+		}
+	}
+
+	ui.repaintChat()
+}
+
+// ReplaceMessage swaps a locally shown message for the server's copy of it,
+// matched by client ID, and reports whether it was found
+func (ui *UI) ReplaceMessage(msg models.Message) bool {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	i := ui.findSent(msg.ClientID)
+	if i < 0 {
+		return false
+	}
+	ui.placeDivider(msg)
+	ui.messages[i] = msg
+	ui.redrawMessage(i)
+	return true
+}
+
+// ApplyChange applies an edit or delete to the message it targets and
+// redraws it in place
+func (ui *UI) ApplyChange(change models.Message) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	for i := len(ui.messages) - 1; i >= 0; i-- {
+		msg := &ui.messages[i]
+		if msg.ID != change.Target {
+			continue
+		}
+
+		switch change.Type {
+		case models.MessageTypeEdit:
+			msg.Content = change.Content
+			msg.Edited = true
+		case models.MessageTypeDelete:
+			delete(ui.activeGIFs, fmt.Sprintf("%s_%d", msg.Username, i))
+			msg.Content = ""
+			msg.GIFName = ""
+			msg.IsGIF = false
+			msg.Deleted = true
+		}
+		ui.redrawMessage(i)
+		return
+	}
+}
+
+// SetDelivery updates the marker shown next to one of the user's own messages
+func (ui *UI) SetDelivery(clientID string, state deliveryState) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	ui.delivery[clientID] = state
+	if i := ui.findSent(clientID); i >= 0 {
+		ui.redrawMessage(i)
+	}
+}
+
+// findSent returns the index of the user's own message with a client ID,
+// skipping errors that refer to it, or -1
+func (ui *UI) findSent(clientID string) int {
+	if clientID == "" {
+		return -1
+	}
+	for i := len(ui.messages) - 1; i >= 0; i-- {
+		if ui.messages[i].ClientID == clientID && ui.messages[i].Username == ui.username {
+			return i
+		}
+	}
+	return -1
+}
+
+// formatTimestamp renders a message time, dimmed with the date for history
+func (ui *UI) formatTimestamp(msg models.Message) string {
+	if msg.History {
+		return utils.ColorFaint(msg.Timestamp.Format("01/02 15:04"))
+	}
+	return utils.ColorWhite(msg.FormatTime())
+}
+
+// formatMessage renders a message as one chat line, or "" when the message
+// has nothing to show
+func (ui *UI) formatMessage(msg models.Message) string {
 	if ui.colorMap[msg.Username] == nil {
 		ui.colorMap[msg.Username] = utils.GetRandomColor(len(ui.colorMap))
 	}
 
 	userColor := ui.colorMap[msg.Username]
-	timestamp := utils.ColorWhite(msg.FormatTime())
+	timestamp := ui.formatTimestamp(msg)
+	contentColor := utils.ColorWhite
+	if msg.History {
+		// Replayed history is dimmed so it reads as backlog, not live traffic
+		contentColor = utils.ColorFaint
+	}
+
+	// The user's own messages show whether the server has confirmed them
+	marker, markerWidth := ui.deliveryMarker(msg)
+
+	// Traffic from rooms other than the active one is tagged with its name
+	roomTag := ""
+	if msg.Room != "" && msg.Room != ui.room {
+		roomTag = utils.ColorFaint("#"+msg.Room) + " "
+	}
+
+	// Deleted messages keep their place as a tombstone
+	if msg.Deleted {
+		username := userColor(fmt.Sprintf("%-12s", msg.Username))
+		return fmt.Sprintf("%s│ %s %s%s │ %s%s│%s",
+			utils.ColorCyan(""), timestamp, roomTag, username,
+			utils.ColorFaint("🗑 message deleted"),
+			padding(ui.terminalWidth-len(msg.Username)-33),
+			utils.ColorCyan(""))
+	}
+	if msg.Edited {
+		marker = utils.ColorFaint(" (edited)") + marker
+		markerWidth += 9
+	}
 
 	var output string
 
 	switch msg.Type {
 	case models.MessageTypeGIF:
 		// Handle GIF message - USE the userColor variable here
-		if gif, exists := gifs.GetGIF(msg.GIFName); exists {
-			ui.startGIFAnimation(gif, msg.Username, timestamp, len(ui.messages))
+		if _, exists := gifs.GetGIF(msg.GIFName); exists {
 			username := userColor(fmt.Sprintf("%-12s", msg.Username)) // Use userColor
-			output = fmt.Sprintf("%s│ %s %s │ %s (GIF: %s)%s",
-				utils.ColorCyan(""), timestamp, username,
-				utils.ColorMagenta("🎬"), msg.GIFName,
+			output = fmt.Sprintf("%s│ %s %s%s │ %s (GIF: %s)%s%s",
+				utils.ColorCyan(""), timestamp, roomTag, username,
+				utils.ColorMagenta("🎬"), msg.GIFName, marker,
+				utils.ColorCyan(""))
+		}
+
+	case models.MessageTypeFile:
+		if msg.File != nil {
+			username := userColor(fmt.Sprintf("%-12s", msg.Username))
+			fileMsg := fmt.Sprintf("📎 %s (%s) · /download %s", msg.File.Name, formatSize(msg.File.Size), msg.File.ID)
+			output = fmt.Sprintf("%s│ %s %s%s │ %s%s%s│%s",
+				utils.ColorCyan(""), timestamp, roomTag, username, utils.ColorGreen(fileMsg), marker,
+				padding(ui.terminalWidth-len([]rune(fileMsg))-len(msg.Username)-15-markerWidth),
 				utils.ColorCyan(""))
 		}
 
 	case models.MessageTypeChat:
 		username := userColor(fmt.Sprintf("%-12s", msg.Username))
-		content := utils.ColorWhite(msg.Content)
-		output = fmt.Sprintf("%s│ %s %s │ %s%s│%s",
-			utils.ColorCyan(""), timestamp, username, content,
-			strings.Repeat(" ", ui.terminalWidth-len(msg.Content)-len(msg.Username)-15),
+		content := contentColor(msg.Content)
+		output = fmt.Sprintf("%s│ %s %s%s │ %s%s%s│%s",
+			utils.ColorCyan(""), timestamp, roomTag, username, content, marker,
+			padding(ui.terminalWidth-len(msg.Content)-len(msg.Username)-15-markerWidth),
+			utils.ColorCyan(""))
+
+	case models.MessageTypeDirect:
+		// DMs get their own badge and arrow so they never look like room traffic
+		peer := fmt.Sprintf("%s → you", userColor(msg.Username))
+		if msg.Username == ui.username {
+			if ui.colorMap[msg.Recipient] == nil {
+				ui.colorMap[msg.Recipient] = utils.GetRandomColor(len(ui.colorMap))
+			}
+			peer = fmt.Sprintf("you → %s", ui.colorMap[msg.Recipient](msg.Recipient))
+		}
+		output = fmt.Sprintf("%s│ %s %s %s │ %s%s%s│%s",
+			utils.ColorCyan(""), timestamp, utils.BgMagenta(" DM "), peer,
+			utils.ColorMagenta(msg.Content), marker,
+			padding(ui.terminalWidth-len(msg.Content)-len(msg.Username)-len(msg.Recipient)-24-markerWidth),
 			utils.ColorCyan(""))
 
 	case models.MessageTypeJoin:
 		joinMsg := fmt.Sprintf("%s joined the chat", userColor(msg.Username))
-		output = fmt.Sprintf("%s│ %s %s %s%s│%s",
+		output = fmt.Sprintf("%s│ %s %s %s%s%s│%s",
 			utils.ColorCyan(""), timestamp,
-			utils.ColorGreen("→"), joinMsg,
-			strings.Repeat(" ", ui.terminalWidth-len(joinMsg)-12),
+			utils.ColorGreen("→"), roomTag, joinMsg,
+			padding(ui.terminalWidth-len(joinMsg)-12),
 			utils.ColorCyan(""))
 
 	case models.MessageTypeLeave:
-		leaveMsg := fmt.Sprintf("%s left the chat", userColor(msg.Username))
-		output = fmt.Sprintf("%s│ %s %s %s%s│%s",
+		// Content carries the server's reason, e.g. "left the chat (ping timeout)"
+		leaveMsg := fmt.Sprintf("%s %s", userColor(msg.Username), msg.Content)
+		output = fmt.Sprintf("%s│ %s %s %s%s%s│%s",
 			utils.ColorCyan(""), timestamp,
-			utils.ColorRed("←"), leaveMsg,
-			strings.Repeat(" ", ui.terminalWidth-len(leaveMsg)-12),
+			utils.ColorRed("←"), roomTag, leaveMsg,
+			padding(ui.terminalWidth-len(leaveMsg)-12),
 			utils.ColorCyan(""))
 
 	case models.MessageTypeSystem:
@@ -171,15 +402,19 @@ func (ui *UI) DisplayMessage(msg models.Message) {
 		output = fmt.Sprintf("%s│ %s %s %s%s│%s",
 			utils.ColorCyan(""), timestamp,
 			utils.ColorYellow("ℹ"), systemMsg,
-			strings.Repeat(" ", ui.terminalWidth-len(msg.Content)-12),
+			padding(ui.terminalWidth-len(msg.Content)-12),
 			utils.ColorCyan(""))
-	}
 
-	if output != "" {
-		fmt.Print("\033[s") // Save cursor position
-		ui.printMessageAtPosition(output)
-		fmt.Print("\033[u") // Restore cursor position
+	case models.MessageTypeError:
+		errorMsg := utils.ColorError(msg.Content)
+		output = fmt.Sprintf("%s│ %s %s %s%s│%s",
+			utils.ColorCyan(""), timestamp,
+			utils.ColorRed("✖"), errorMsg,
+			padding(ui.terminalWidth-len(msg.Content)-12),
+			utils.ColorCyan(""))
 	}
+
+	return output
 }
 
 // startGIFAnimation starts animating a GIF
@@ -201,9 +436,12 @@ func (ui *UI) startGIFAnimation(gif gifs.AnimatedGIF, username, timestamp string
 	go ui.animateGIF(animationID)
 }
 
-// animateGIF runs the GIF animation
+// animateGIF runs the GIF animation. It runs on its own goroutine and takes
+// ui.mu for each frame.
 func (ui *UI) animateGIF(animationID string) {
+	ui.mu.Lock()
 	animation, exists := ui.activeGIFs[animationID]
+	ui.mu.Unlock()
 	if !exists {
 		return
 	}
@@ -223,10 +461,13 @@ func (ui *UI) animateGIF(animationID string) {
 			time.Sleep(frame.Duration)
 
 			// Update the frame in the chat
+			ui.mu.Lock()
 			ui.updateGIFFrame(animationID, frameIndex, frame.Content)
+			_, exists := ui.activeGIFs[animationID]
+			ui.mu.Unlock()
 
 			// Check if animation should continue
-			if _, exists := ui.activeGIFs[animationID]; !exists {
+			if !exists {
 				return
 			}
 		}
@@ -234,7 +475,9 @@ func (ui *UI) animateGIF(animationID string) {
 	}
 
 	// Clean up animation
+	ui.mu.Lock()
 	delete(ui.activeGIFs, animationID)
+	ui.mu.Unlock()
 }
 
 // updateGIFFrame updates a specific GIF frame in the chat
@@ -244,12 +487,15 @@ func (ui *UI) updateGIFFrame(animationID string, frameIndex int, frameContent st
 		return
 	}
 
+	// Frames of messages scrolled out of view are skipped
+	line, visible := ui.lineFor(animation.Position)
+	if !visible {
+		return
+	}
+
 	// Save cursor position
 	fmt.Print("\033[s")
 
-	// Calculate the line position
-	line := 6 + animation.Position
-
 	// Ensure user color exists in colorMap
 	if ui.colorMap[animation.Username] == nil {
 		ui.colorMap[animation.Username] = utils.GetRandomColor(len(ui.colorMap))
@@ -266,46 +512,119 @@ func (ui *UI) updateGIFFrame(animationID string, frameIndex int, frameContent st
 	fmt.Print("\033[u")
 }
 
-// printMessageAtPosition prints a message in the chat area
-func (ui *UI) printMessageAtPosition(message string) {
-	// Calculate next message line (scrolling if needed)
-	messageCount := len(ui.messages)
-	maxVisibleMessages := ui.chatHeight - 3
-
-	if messageCount <= maxVisibleMessages {
-		// Print at next available line
-		line := 6 + messageCount
-		fmt.Printf("\033[%d;1H%s\n", line, message)
-	} else {
-		// Scroll chat area
-		ui.scrollChatArea()
-		fmt.Printf("\033[%d;1H%s\n", ui.chatHeight+2, message)
+// renderMessage returns the terminal rows of a message: the new messages
+// divider, the quote of the message it replies to, its line and the
+// reaction tally under it
+func (ui *UI) renderMessage(msg models.Message) []string {
+	line := ui.formatMessage(msg)
+	if line == "" {
+		return nil
+	}
+	var rows []string
+	if ui.startsUnread(msg) {
+		rows = append(rows, ui.formatDivider(msg.Room))
+	}
+	if quote := ui.formatQuote(msg); quote != "" {
+		rows = append(rows, quote)
+	}
+	rows = append(rows, line)
+	if tally := ui.formatReactions(msg); tally != "" {
+		rows = append(rows, tally)
 	}
+	return rows
 }
 
-// scrollChatArea scrolls the chat messages up
-func (ui *UI) scrollChatArea() {
-	startLine := 7
-	endLine := ui.chatHeight + 2
+// shownMessage is a message on screen together with its rendered rows
+type shownMessage struct {
+	index int
+	rows  []string
+}
 
-	// Move each line up by one
-	for line := startLine; line < endLine; line++ {
+// visibleMessages returns the newest messages in view that fit in the chat
+// area, oldest first
+func (ui *UI) visibleMessages() []shownMessage {
+	var shown []shownMessage
+	used := 0
+	for i := len(ui.messages) - 1; i >= 0; i-- {
+		if !ui.inView(ui.messages[i]) {
+			continue
+		}
+		rows := ui.renderMessage(ui.messages[i])
+		if used+len(rows) > ui.chatRows() {
+			break
+		}
+		used += len(rows)
+		shown = append(shown, shownMessage{index: i, rows: rows})
+	}
+	slices.Reverse(shown)
+	return shown
+}
+
+// repaintChat redraws the chat area with the newest messages that fit,
+// scrolling older ones off the top
+func (ui *UI) repaintChat() {
+	fmt.Print("\033[s") // Save cursor position
+	line := chatTop
+	for _, shown := range ui.visibleMessages() {
+		for _, row := range shown.rows {
+			fmt.Printf("\033[%d;1H\033[K%s", line, row)
+			line++
+		}
+	}
+	for ; line < chatTop+ui.chatRows(); line++ {
 		fmt.Printf("\033[%d;1H\033[K", line) // Clear line
-		if line < endLine-1 {
-			// This would need more complex logic to actually move text up
-			// For now, we'll just clear and let new messages appear
+	}
+	fmt.Print("\033[u") // Restore cursor position
+}
+
+// redrawMessage repaints the chat area if a changed message is on screen
+func (ui *UI) redrawMessage(index int) {
+	if _, visible := ui.lineFor(index); visible {
+		ui.repaintChat()
+	}
+}
+
+// chatTop is the terminal line of the first chat message, below the header
+const chatTop = 8
+
+// chatRows is how many terminal rows the chat area has
+func (ui *UI) chatRows() int {
+	return max(1, ui.chatHeight-3)
+}
+
+// lineFor returns the terminal line of a message's own row, below any
+// divider or quote, and whether it is on screen
+func (ui *UI) lineFor(index int) (int, bool) {
+	line := chatTop
+	for _, shown := range ui.visibleMessages() {
+		if shown.index == index {
+			if ui.startsUnread(ui.messages[index]) {
+				line++
+			}
+			if ui.formatQuote(ui.messages[index]) != "" {
+				line++
+			}
+			return line, true
 		}
+		line += len(shown.rows)
 	}
+	return 0, false
 }
 
-// positionCursorForChat positions cursor in chat input area
+// positionCursorForChat positions cursor in chat input area. It takes ui.mu.
 func (ui *UI) positionCursorForChat() {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
 	// Position cursor in input bar
 	fmt.Printf("\033[%d;5H", ui.terminalHeight-1)
 }
 
 // ClearInput clears the input bar
 func (ui *UI) ClearInput() {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
 	// Clear input line
 	fmt.Printf("\033[%d;5H", ui.terminalHeight-1)
 	fmt.Printf("%s%s",
@@ -317,31 +636,47 @@ func (ui *UI) ClearInput() {
 
 // ShowUserList displays online users in a side panel
 func (ui *UI) ShowUserList() {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
 	// Save cursor, show users, restore cursor
 	fmt.Print("\033[s")
 
-	fmt.Printf("\033[%d;%dH", 7, ui.terminalWidth-25)
+	fmt.Printf("\033[%d;%dH", chatTop, ui.terminalWidth-25)
 	userBox := pterm.DefaultBox.
 		WithTitle("👥 Online").
 		WithTitleTopCenter().
 		WithBoxStyle(pterm.NewStyle(pterm.FgGreen))
 
+	users := ui.users[ui.room]
 	userList := ""
-	for i, user := range ui.users {
-		if ui.colorMap[user] == nil {
-			ui.colorMap[user] = utils.GetRandomColor(len(ui.colorMap))
+	for i, user := range users {
+		if ui.colorMap[user.Username] == nil {
+			ui.colorMap[user.Username] = utils.GetRandomColor(len(ui.colorMap))
 		}
-		userColor := ui.colorMap[user]
+		userColor := ui.colorMap[user.Username]
 
 		status := "●"
-		if user == ui.username {
+		if user.Status != models.UserStatusOnline {
+			status = utils.ColorYellow("●")
+		} else if user.Username == ui.username {
 			status = utils.ColorGreen("● (you)")
 		} else {
 			status = utils.ColorGreen("●")
 		}
 
-		userList += fmt.Sprintf("%s %s", status, userColor(user))
-		if i < len(ui.users)-1 {
+		// Owners and moderators are marked as on IRC
+		badge := ""
+		switch user.Role {
+		case models.RoleOwner:
+			badge = utils.ColorYellow("★")
+		case models.RoleModerator:
+			badge = utils.ColorCyan("@")
+		}
+
+		userList += fmt.Sprintf("%s %s%s %s", status, badge, userColor(user.Username),
+			utils.ColorWhite("since "+user.JoinedAt.Format("15:04")))
+		if i < len(users)-1 {
 			userList += "\n"
 		}
 	}
@@ -361,35 +696,70 @@ func (ui *UI) ShowHelp() {
 	helpMsg := models.Message{
 		Type:      models.MessageTypeSystem,
 		Username:  "system",
-		Content:   "Commands: /quit, /exit, /help, /users, /clear, /gif <name>, /gifs",
+		Content:   "Commands: /quit, /exit, /help, /users, /clear, /gif <name>, /gifs, /join <room> [key], /part [room], /msg <user> <text>, /rooms, /retry, /edit [^N] <text>, /delete [^N], /react [^N] <emoji>, /reply [^N] <text>, /thread [^N], /back, /seen [^N], /topic [text], /describe <text>, /private, /public, /passphrase <phrase>, /invite [user], /send <path>, /download <id> [dest], /kick, /ban, /unban, /mute, /unmute, /op, /deop",
 		Timestamp: time.Now(),
 	}
 	ui.DisplayMessage(helpMsg)
 }
 
+// ShowRoomList prints the server's room directory in the chat area
+func (ui *UI) ShowRoomList(rooms []models.Room) {
+	if len(rooms) == 0 {
+		ui.showNotice("No active rooms")
+		return
+	}
+
+	ui.showNotice(fmt.Sprintf("Active rooms (%d):", len(rooms)))
+	for _, room := range rooms {
+		ui.showNotice("  #" + formatRoom(room))
+	}
+}
+
+// showNotice prints a local system line. It takes ui.mu.
+func (ui *UI) showNotice(content string) {
+	ui.DisplayMessage(models.Message{
+		Type:      models.MessageTypeSystem,
+		Username:  "system",
+		Content:   content,
+		Timestamp: time.Now(),
+	})
+}
+
 // ClearChat clears the chat area
 func (ui *UI) ClearChat() {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
 	// Clear chat area but keep header and input bar
-	for line := 7; line <= ui.chatHeight+2; line++ {
-		fmt.Printf("\033[%d;1H\033[K", line)
+	for i := 0; i < ui.chatRows(); i++ {
+		fmt.Printf("\033[%d;1H\033[K", chatTop+i)
 	}
 
 	// Reset messages
 	ui.messages = make([]models.Message, 0)
 
 	// Redraw chat border
-	fmt.Printf("\033[7;1H%s│%s%s│%s\n",
+	fmt.Printf("\033[%d;1H%s│%s│%s\n", chatTop,
 		utils.ColorCyan(""),
 		strings.Repeat(" ", ui.terminalWidth-2),
 		utils.ColorCyan(""))
 }
 
-// ShowDisconnected shows disconnection message
-func (ui *UI) ShowDisconnected() {
+// ShowDisconnected shows disconnection message, with the server's reason if it gave one
+func (ui *UI) ShowDisconnected(reason string, reconnecting bool) {
+	content := "⚠️ Connection lost!"
+	if reason != "" {
+		content = fmt.Sprintf("⚠️ Disconnected: %s.", reason)
+	}
+	if reconnecting {
+		content += " Reconnecting - messages you send meanwhile are queued."
+	} else {
+		content += " Type /quit to exit."
+	}
 	disconnectMsg := models.Message{
 		Type:      models.MessageTypeSystem,
 		Username:  "system",
-		Content:   "⚠️ Connection lost! Type /quit to exit.",
+		Content:   content,
 		Timestamp: time.Now(),
 	}
 	ui.DisplayMessage(disconnectMsg)
@@ -397,6 +767,9 @@ func (ui *UI) ShowDisconnected() {
 
 // ShowGoodbye shows farewell message
 func (ui *UI) ShowGoodbye() {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
 	utils.ClearScreen()
 	goodbyeBox := pterm.DefaultBox.
 		WithTitle("👋 Goodbye").
@@ -411,15 +784,34 @@ func (ui *UI) ShowGoodbye() {
 
 // UpdateInputBar updates the input bar with current text
 func (ui *UI) UpdateInputBar(text string) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	// Long lines scroll so the end being typed stays in view
+	shown := []rune(text)
+	if width := ui.terminalWidth - 7; len(shown) > width {
+		shown = shown[len(shown)-max(width, 0):]
+	}
+
 	fmt.Printf("\033[%d;5H", ui.terminalHeight-1)
-	fmt.Printf("%s%s%s",
-		utils.ColorWhite(text),
-		strings.Repeat(" ", ui.terminalWidth-6-len(text)),
-		"")
-	fmt.Printf("\033[%d;%dH", ui.terminalHeight-1, 5+len(text))
+	fmt.Printf("%s%s",
+		utils.ColorWhite(string(shown)),
+		padding(ui.terminalWidth-6-len(shown)))
+	fmt.Printf("\033[%d;%dH", ui.terminalHeight-1, 5+len(shown))
 }
 
-// UpdateUserList updates the online users list
-func (ui *UI) UpdateUserList(users []string) {
-	ui.users = users
+// UpdateUserList updates the online users list of a room
+func (ui *UI) UpdateUserList(room string, users []models.User) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	ui.users[room] = users
+}
+
+// padding returns n spaces, or none when the line is already too wide
+func padding(n int) string {
+	if n < 0 {
+		return ""
+	}
+	return strings.Repeat(" ", n)
 }
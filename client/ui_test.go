@@ -0,0 +1,88 @@
+package client
+
+import (
+	"fmt"
+	"sync"
+	"terminal-chat/models"
+	"testing"
+)
+
+func TestUIIsSafeAcrossGoroutines(t *testing.T) {
+	ui := NewUI("alice", "general")
+	own := func(i int) models.Message {
+		msg := *models.NewMessage(models.MessageTypeChat, "alice", fmt.Sprintf("mine %d", i), "general")
+		msg.ClientID = fmt.Sprintf("s-%d", i)
+		return msg
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	// The input goroutine shows typed messages and notices
+	go func() {
+		defer wg.Done()
+		for i := range 200 {
+			ui.SetDelivery(own(i).ClientID, deliveryPending)
+			ui.DisplayMessage(own(i))
+			ui.showNotice("notice")
+			ui.SetConnectionStatus(stateConnected, i%3)
+		}
+	}()
+	// The reader goroutine applies what the server says
+	go func() {
+		defer wg.Done()
+		for i := range 200 {
+			msg := *models.NewMessage(models.MessageTypeChat, "bob", "theirs", "general")
+			msg.ID, msg.Seq = fmt.Sprintf("id-%d", i), uint64(i+1)
+			ui.SetReadMark("general", 0)
+			ui.DisplayMessage(msg)
+			ui.SetDelivery(own(i).ClientID, deliverySent)
+			ui.ApplyReactions(models.Message{Target: msg.ID, Reactions: []models.Reaction{{Emoji: "👍", Count: 1}}})
+			ui.UpdateUserList("general", []models.User{{Username: "bob"}})
+			ui.SetRoomInfo(models.Room{Name: "general", Topic: "chat"})
+		}
+	}()
+	// Transfers report progress from their own goroutines
+	go func() {
+		defer wg.Done()
+		for i := range 200 {
+			ui.SetProgress(progressText("⬆️", "notes.txt", int64(i), 200))
+		}
+	}()
+	wg.Wait()
+
+	if got := len(ui.messages); got != 600 {
+		t.Errorf("%d messages shown, want 600", got)
+	}
+}
+
+func TestInputRedrawSharesUILock(t *testing.T) {
+	ui := NewUI("alice", "general")
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	// The input goroutine redraws the input bar as keys arrive, and
+	// switches rooms on /join
+	go func() {
+		defer wg.Done()
+		for i := range 200 {
+			ui.UpdateInputBar(fmt.Sprintf("typing %d", i))
+			ui.SetRooms([]string{"general", "random"}[i%2], []string{"general", "random"})
+			ui.ClearInput()
+			ui.positionCursorForChat()
+		}
+	}()
+	// The reader goroutine redraws the typing line and the chat area
+	go func() {
+		defer wg.Done()
+		for i := range 200 {
+			ui.SetTyping("general", "bob", i%2 == 0)
+			ui.DisplayMessage(*models.NewMessage(models.MessageTypeChat, "bob", "hi", "general"))
+			ui.ClearTyping("")
+		}
+	}()
+	wg.Wait()
+
+	if len(ui.typing) != 0 {
+		t.Errorf("typing line left at %v", ui.typing)
+	}
+}
@@ -10,8 +10,10 @@ import (
 )
 
 func main() {
-	var host = flag.String("host", "localhost", "Host to connect to")
-	var port = flag.String("port", "8080", "Port to connect to")
+	cfg := client.DefaultConfig()
+	flag.StringVar(&cfg.Host, "host", cfg.Host, "Host to connect to")
+	flag.StringVar(&cfg.Port, "port", cfg.Port, "Port to connect to")
+	cfg.BindFlags(flag.CommandLine)
 	flag.Parse()
 
 	// Clear screen and show client banner
@@ -19,9 +21,9 @@ func main() {
 	showClientBanner()
 
 	fmt.Printf("\n%s Connecting to chat server at %s:%s...\n",
-		utils.ColorBlue("🔗"), *host, *port)
+		utils.ColorBlue("🔗"), cfg.Host, cfg.Port)
 
-	client.StartClient(*host, *port)
+	client.StartClient(cfg)
 }
 
 func showClientBanner() {
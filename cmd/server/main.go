@@ -10,7 +10,9 @@ import (
 )
 
 func main() {
-	var port = flag.String("port", "8080", "Port to run server on")
+	cfg := server.DefaultConfig()
+	flag.StringVar(&cfg.Port, "port", cfg.Port, "Port to run server on")
+	cfg.BindFlags(flag.CommandLine)
 	flag.Parse()
 
 	// Clear screen and show server banner
@@ -18,9 +20,9 @@ func main() {
 	showServerBanner()
 
 	fmt.Printf("\n%s Starting terminal chat server on port %s...\n",
-		utils.ColorGreen("🚀"), *port)
+		utils.ColorGreen("🚀"), cfg.Port)
 
-	server.StartServer(*port)
+	server.StartServer(cfg)
 }
 
 func showServerBanner() {
@@ -15,6 +15,10 @@ func main() {
 	var mode = flag.String("mode", "", "Mode: server or client")
 	var port = flag.String("port", "8080", "Port to run server on")
 	var host = flag.String("host", "localhost", "Host to connect to")
+	serverCfg := server.DefaultConfig()
+	serverCfg.BindFlags(flag.CommandLine)
+	clientCfg := client.DefaultConfig()
+	clientCfg.BindFlags(flag.CommandLine)
 	flag.Parse()
 
 	// Clear screen and show banner
@@ -29,11 +33,14 @@ func main() {
 	case "server":
 		fmt.Printf("\n%s Starting server on port %s...\n",
 			utils.ColorGreen("🚀"), *port)
-		server.StartServer(*port)
+		serverCfg.Port = *port
+		server.StartServer(serverCfg)
 	case "client":
 		fmt.Printf("\n%s Connecting to %s:%s...\n",
 			utils.ColorBlue("🔗"), *host, *port)
-		client.StartClient(*host, *port)
+		clientCfg.Host = *host
+		clientCfg.Port = *port
+		client.StartClient(clientCfg)
 	default:
 		log.Fatal("Invalid mode. Use 'server' or 'client'")
 	}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Credentials is the body of /register and /login requests
+type Credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Session is returned by /login; the token authenticates the WebSocket upgrade
+type Session struct {
+	Username  string    `json:"username"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// APIError is the body of failed HTTP API calls
+type APIError struct {
+	Error string `json:"error"`
+}
@@ -15,6 +15,48 @@ const (
 	MessageTypeSystem   MessageType = "system"
 	MessageTypeUserList MessageType = "userlist"
 	MessageTypeGIF      MessageType = "gif" // New GIF type
+	MessageTypeError    MessageType = "error"
+	MessageTypeJoinRoom MessageType = "join_room" // Client asks to join a room
+	MessageTypePartRoom MessageType = "part_room" // Client asks to leave a room
+	MessageTypeDirect   MessageType = "dm"        // Private message to one user
+	MessageTypeRoomList MessageType = "rooms"     // Room directory request and reply
+	MessageTypeAck      MessageType = "ack"       // Tells the sender a message was delivered
+	MessageTypeBackfill MessageType = "backfill"  // Client asks for a range of room messages again
+	MessageTypeEdit     MessageType = "edit"      // Replaces the text of the Target message
+	MessageTypeDelete   MessageType = "delete"    // Replaces the Target message with a tombstone
+	MessageTypeReaction MessageType = "reaction"  // Toggles an emoji on the Target message
+
+	MessageTypeTypingStart MessageType = "typing_start" // The sender started composing in Room
+	MessageTypeTypingStop  MessageType = "typing_stop"  // The sender stopped composing in Room
+
+	MessageTypeRead     MessageType = "read"     // How far the user has read in Room, as a Seq
+	MessageTypeReceipts MessageType = "receipts" // Who has read Room up to Seq, asked and answered
+	MessageTypeModerate MessageType = "moderate" // A moderator's Action on User in Room
+	MessageTypeWelcome  MessageType = "welcome"  // First frame on a connection: the Username it goes by
+	MessageTypeTopic    MessageType = "topic"    // Sets a room's topic or description; answered with RoomInfo
+	MessageTypeAccess   MessageType = "access"   // Changes who may join Room: Action is one of the Access* settings
+
+	MessageTypeFileOffer   MessageType = "file_offer"   // Proposes an upload of File; the reply's File.ID starts it
+	MessageTypeFile        MessageType = "file"         // A File shared in Room
+	MessageTypeFileRequest MessageType = "file_request" // Asks for file Target; the reply's File precedes its chunks
+)
+
+// Error codes carried by MessageTypeError
+const (
+	ErrCodeBadMessage    = "bad_message"
+	ErrCodeForbiddenType = "forbidden_type"
+	ErrCodeWrongRoom     = "wrong_room"
+	ErrCodeBadRoom       = "bad_room"
+	ErrCodeAlreadyJoined = "already_joined"
+	ErrCodeUserOffline   = "user_offline"
+	ErrCodeNotFound      = "not_found"
+	ErrCodeNotAllowed    = "not_allowed"
+	ErrCodeRateLimited   = "rate_limited"
+	ErrCodeBanned        = "banned"
+	ErrCodeMuted         = "muted"
+	ErrCodeInviteOnly    = "invite_only"
+	ErrCodePassphrase    = "passphrase"
+	ErrCodeFileRefused   = "file_refused"
 )
 
 // Add GIF-specific fields to Message struct
@@ -25,22 +67,140 @@ type Message struct {
 	Room      string      `json:"room"`
 	Timestamp time.Time   `json:"timestamp"`
 	Color     string      `json:"color,omitempty"`
-	GIFName   string      `json:"gif_name,omitempty"` // GIF identifier
-	IsGIF     bool        `json:"is_gif,omitempty"`   // Flag for GIF messages
+	GIFName   string      `json:"gif_name,omitempty"`  // GIF identifier
+	IsGIF     bool        `json:"is_gif,omitempty"`    // Flag for GIF messages
+	Code      string      `json:"code,omitempty"`      // Error code for error messages
+	Users     []User      `json:"users,omitempty"`     // Member list for userlist messages
+	History   bool        `json:"history,omitempty"`   // Replayed from history, not live
+	Recipient string      `json:"recipient,omitempty"` // Target user of a direct message
+	Rooms     []Room      `json:"rooms,omitempty"`     // Directory for room list replies
+	ID        string      `json:"id,omitempty"`        // Assigned by the server to every delivered message
+	ClientID  string      `json:"client_id,omitempty"` // Chosen by the sender to match acks and errors
+	Seq       uint64      `json:"seq,omitempty"`       // Position in the room; on joins, the room's latest
+	Since     uint64      `json:"since,omitempty"`     // Backfill and rejoin: send messages after this seq
+	Until     uint64      `json:"until,omitempty"`     // Backfill: stop at this seq, 0 for the latest
+	Target    string      `json:"target,omitempty"`    // ID of the message an edit or delete applies to
+	Edited    bool        `json:"edited,omitempty"`    // Content was changed after sending
+	Deleted   bool        `json:"deleted,omitempty"`   // Removed by its author or a moderator
+	Emoji     string      `json:"emoji,omitempty"`     // Reaction requests: the emoji to toggle
+	Reactions []Reaction  `json:"reactions,omitempty"` // Reaction tally, in the order first used
+	ReplyTo   string      `json:"reply_to,omitempty"`  // ID of the message this one answers
+	Thread    string      `json:"thread,omitempty"`    // ID of the first message of the thread
+	Quote     *Quote      `json:"quote,omitempty"`     // Excerpt of the ReplyTo message
+	ReadBy    []string    `json:"read_by,omitempty"`   // Receipts: users who have read up to Seq
+	Unread    []string    `json:"unread,omitempty"`    // Receipts: members who have not
+	Action    string      `json:"action,omitempty"`    // Moderation: one of the Mod* actions
+	User      string      `json:"user,omitempty"`      // Moderation: the user acted on
+	Duration  int64       `json:"duration,omitempty"`  // Moderation: length of a mute in seconds
+	BanIP     bool        `json:"ban_ip,omitempty"`    // Moderation: also ban the user's addresses
+	RoomInfo  *Room       `json:"room_info,omitempty"` // Topic messages: the room's metadata
+	MOTD      string      `json:"motd,omitempty"`      // Welcome: the server's message of the day
+	Limits    *Limits     `json:"limits,omitempty"`    // Welcome: the rate limits each user is held to
+	Key       string      `json:"key,omitempty"`       // Joins: passphrase or invite token; access: new passphrase
+	File      *File       `json:"file,omitempty"`      // File messages: the file offered, shared or sent
+	Author    string      `json:"author,omitempty"`    // Stored messages: who may change them; never sent to clients
+}
+
+// Limits are the rate limits a server holds each user to, announced so
+// clients can pace what they send in bulk
+type Limits struct {
+	Messages Rate `json:"messages"` // Chat, direct messages, edits and reactions
+	GIFs     Rate `json:"gifs"`
+	Joins    Rate `json:"joins"` // Room joins and parts
+}
+
+// Rate allows Burst sends at once, refilled at PerSecond
+type Rate struct {
+	PerSecond float64 `json:"per_second"`
+	Burst     int     `json:"burst"`
+}
+
+// Quote is the part of a parent message shown above a reply
+type Quote struct {
+	Username string `json:"username"`
+	Content  string `json:"content"`
 }
 
+// File describes a file shared in a room
+type File struct {
+	ID     string `json:"id,omitempty"` // Assigned by the server when it accepts the upload
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"` // Hex digest of the content, checked at both ends
+}
+
+// File content travels in binary frames of up to FileChunkSize bytes, each
+// led by the FileIDSize byte ID of the file it belongs to
+const (
+	FileIDSize    = 10
+	FileChunkSize = 32 * 1024
+)
+
+// Reaction counts one emoji on a message
+type Reaction struct {
+	Emoji string   `json:"emoji"`
+	Count int      `json:"count"`
+	Users []string `json:"users"`
+}
+
+// User statuses reported in userlist messages
+const (
+	UserStatusOnline = "online"
+)
+
+// Room roles, from most to least powerful
+const (
+	RoleOwner     = "owner"
+	RoleModerator = "moderator"
+	RoleMember    = "member"
+)
+
+// Moderation actions carried by MessageTypeModerate
+const (
+	ModKick   = "kick"
+	ModBan    = "ban"
+	ModUnban  = "unban"
+	ModMute   = "mute"
+	ModUnmute = "unmute"
+	ModOp     = "op"
+	ModDeop   = "deop"
+)
+
+// Access settings carried by MessageTypeAccess
+const (
+	AccessPrivate    = "private"    // Only invited users may join
+	AccessPassphrase = "passphrase" // Joining needs Key unless invited
+	AccessPublic     = "public"     // Anyone may join
+	AccessInvite     = "invite"     // Lets User in, or issues a token when User is empty
+)
+
+// Room fields a topic message can set, given as its Action
+const (
+	RoomFieldTopic       = "topic"
+	RoomFieldDescription = "description"
+)
+
 // User represents a connected user
 type User struct {
 	Username string    `json:"username"`
 	Room     string    `json:"room"`
 	JoinedAt time.Time `json:"joined_at"`
 	Color    string    `json:"color"`
+	Status   string    `json:"status"`
+	Role     string    `json:"role,omitempty"` // Role in Room; empty for members
 }
 
 // Room represents a chat room
 type Room struct {
-	Name  string `json:"name"`
-	Users []User `json:"users"`
+	Name    string `json:"name"`
+	Users   []User `json:"users,omitempty"`
+	Members int    `json:"members"`
+	Topic   string `json:"topic,omitempty"`
+
+	TopicBy     string    `json:"topic_by,omitempty"`    // Who set the topic
+	Description string    `json:"description,omitempty"` // Longer text shown by /topic
+	CreatedBy   string    `json:"created_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at,omitzero"`
 }
 
 // ToJSON converts message to JSON
@@ -67,6 +227,40 @@ func NewMessage(msgType MessageType, username, content, room string) *Message {
 	}
 }
 
+// NewErrorMessage creates an error message addressed to a single client
+func NewErrorMessage(code, content, room string) *Message {
+	msg := NewMessage(MessageTypeError, "system", content, room)
+	msg.Code = code
+	return msg
+}
+
+// NewAckMessage confirms to the sender that msg was accepted and delivered
+func NewAckMessage(msg *Message) *Message {
+	ack := NewMessage(MessageTypeAck, "system", "", msg.Room)
+	ack.ID = msg.ID
+	ack.ClientID = msg.ClientID
+	ack.Seq = msg.Seq
+	ack.Timestamp = msg.Timestamp
+	return ack
+}
+
+// ThreadRoot returns the ID of the thread a message belongs to; a message
+// that starts a thread is its own root
+func (m *Message) ThreadRoot() string {
+	if m.Thread != "" {
+		return m.Thread
+	}
+	return m.ID
+}
+
+// Sequenced reports whether a message holds a place in its room's sequence
+func (m *Message) Sequenced() bool {
+	if m.Seq == 0 || m.Room == "" {
+		return false
+	}
+	return m.Type == MessageTypeChat || m.Type == MessageTypeGIF
+}
+
 // FormatTime returns formatted timestamp
 func (m *Message) FormatTime() string {
 	return m.Timestamp.Format("15:04:05")
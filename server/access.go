@@ -0,0 +1,210 @@
+package server
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"terminal-chat/models"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// passphraseCost keeps passphrase checks cheap enough for the hub goroutine;
+// joins are rate limited, which slows guessing far more than bcrypt would
+const passphraseCost = 8
+
+// inviteTTL is how long an invite token stays usable
+const inviteTTL = 24 * time.Hour
+
+// admit checks that a client may enter a private or passphrase protected
+// room, with key holding an invite token or the passphrase, and tells it why
+// not when it may not. Whoever gets in is remembered, so rejoining after a
+// reconnect needs no key.
+func (h *Hub) admit(client *Client, room, key string) bool {
+	state := h.roomStates.Find(room)
+	if !state.Hidden() || h.role(room, client) != models.RoleMember ||
+		(client.registered && state.allowed(client.Username)) ||
+		(client.resume != "" && state.guests[client.resume]) {
+		return true
+	}
+
+	expires, invited := state.Invites[key]
+	switch {
+	case key == "":
+	case invited && time.Now().Before(expires):
+		delete(state.Invites, key) // Tokens are good for one user
+		log.Printf("🎟️ %s used an invite token for room '%s'", client.Username, room)
+		h.remember(state, client)
+		h.saveRoomStates()
+		return true
+	case !state.Private && bcrypt.CompareHashAndPassword([]byte(state.Passphrase), []byte(key)) == nil:
+		log.Printf("🔑 %s gave the passphrase for room '%s'", client.Username, room)
+		h.remember(state, client)
+		h.saveRoomStates()
+		return true
+	}
+
+	log.Printf("🔒 Refused %s entry to hidden room '%s'", client.Username, room)
+	switch {
+	case state.Private:
+		h.sendError(client, models.ErrCodeInviteOnly, fmt.Sprintf("Room '%s' is invite-only", room), room)
+	case key == "":
+		h.sendError(client, models.ErrCodePassphrase,
+			fmt.Sprintf("Room '%s' needs a passphrase: /join %s <passphrase>", room, room), room)
+	default:
+		h.sendError(client, models.ErrCodePassphrase, fmt.Sprintf("Wrong passphrase for room '%s'", room), room)
+	}
+	return false
+}
+
+// setAccess changes who may join a room. Only the owner may close or open
+// a room; its moderators may invite people into it.
+func (h *Hub) setAccess(client *Client, msg *models.Message) {
+	room, ok := h.resolveRoom(client, msg.Room)
+	if !ok {
+		h.rejectMessage(client, msg, models.ErrCodeWrongRoom,
+			fmt.Sprintf("You are not a member of room '%s'", msg.Room))
+		return
+	}
+
+	state := h.roomStates.Find(room)
+	needed := models.RoleOwner
+	if msg.Action == models.AccessInvite {
+		needed = models.RoleModerator
+	}
+	if roleRank[h.role(room, client)] < roleRank[needed] {
+		what := "change who can join"
+		if msg.Action == models.AccessInvite {
+			what = "invite people"
+		}
+		h.rejectMessage(client, msg, models.ErrCodeNotAllowed,
+			fmt.Sprintf("Only the room's %ss can %s", needed, what))
+		return
+	}
+
+	state = h.roomStates.Get(room)
+	var notice string
+	switch msg.Action {
+	case models.AccessInvite:
+		if !state.Hidden() {
+			h.rejectMessage(client, msg, models.ErrCodeBadMessage,
+				fmt.Sprintf("Anyone can join #%s, no invite is needed", room))
+			return
+		}
+		user := strings.TrimSpace(msg.User)
+		if user != "" {
+			holder, registered := h.accounts.Holder(user)
+			if !registered {
+				h.rejectMessage(client, msg, models.ErrCodeNotFound,
+					fmt.Sprintf("%s has no account; use /invite alone for a token to pass on", user))
+				return
+			}
+			user = holder
+		}
+		notice = h.invite(client, room, state, user)
+		if notice == "" {
+			return
+		}
+
+	case models.AccessPrivate:
+		state.Private, state.Passphrase = true, ""
+		h.allowMembers(state, room)
+		notice = fmt.Sprintf("🔒 %s made #%s invite-only", client.Username, room)
+
+	case models.AccessPassphrase:
+		if msg.Key == "" {
+			h.rejectMessage(client, msg, models.ErrCodeBadMessage, "Give the passphrase to set")
+			return
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(msg.Key), passphraseCost)
+		if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+			h.rejectMessage(client, msg, models.ErrCodeBadMessage, "That passphrase is too long")
+			return
+		}
+		if err != nil {
+			log.Printf("⚠️ Failed to hash the passphrase for room '%s': %v", room, err)
+			h.rejectMessage(client, msg, models.ErrCodeBadMessage, "Could not set the passphrase")
+			return
+		}
+		state.Private, state.Passphrase = false, string(hash)
+		h.allowMembers(state, room)
+		notice = fmt.Sprintf("🔑 %s set a passphrase on #%s", client.Username, room)
+
+	case models.AccessPublic:
+		state.Private, state.Passphrase = false, ""
+		state.Allowed, state.Invites, state.guests = nil, nil, nil
+		notice = fmt.Sprintf("🔓 %s opened #%s to everyone", client.Username, room)
+
+	default:
+		h.rejectMessage(client, msg, models.ErrCodeBadMessage,
+			fmt.Sprintf("Unknown access setting %q", msg.Action))
+		return
+	}
+
+	log.Print(notice)
+	h.saveRoomStates()
+	announcement := models.NewMessage(models.MessageTypeSystem, "system", notice, room)
+	h.broadcastToRoom(announcement, room)
+}
+
+// invite lets an account into a hidden room, telling them if they are online,
+// and returns the notice for the room. Without a user it hands the inviter
+// a token to pass on instead, which the room does not hear about.
+func (h *Hub) invite(client *Client, room string, state *RoomState, user string) string {
+	if user == "" {
+		token := rand.Text()
+		if state.Invites == nil {
+			state.Invites = make(map[string]time.Time)
+		}
+		state.Invites[token] = time.Now().Add(inviteTTL)
+		h.saveRoomStates()
+
+		log.Printf("🎟️ %s created an invite token for room '%s'", client.Username, room)
+		h.sendNotice(client, fmt.Sprintf("🎟️ Invite for #%s, good for one person for %s: /join %s %s",
+			room, inviteTTL, room, token))
+		return ""
+	}
+
+	state.allow(user)
+	canonical := canonicalName(user)
+	for other := range h.clients {
+		if canonicalName(other.Username) == canonical {
+			h.sendNotice(other, fmt.Sprintf("🎟️ %s invited you to #%s. Type /join %s to enter.",
+				client.Username, room, room))
+		}
+	}
+	return fmt.Sprintf("🎟️ %s invited %s to #%s", client.Username, user, room)
+}
+
+// allowMembers lets everyone already in a room back in once it is hidden
+func (h *Hub) allowMembers(state *RoomState, room string) {
+	for member := range h.rooms[room] {
+		h.remember(state, member)
+	}
+}
+
+// remember lets a client that got into a hidden room back in without a
+// key: accounts for good, guests for as long as their session lasts
+func (h *Hub) remember(state *RoomState, client *Client) {
+	switch {
+	case client.registered:
+		state.allow(client.Username)
+	case client.resume != "":
+		state.allowGuest(client.resume)
+	}
+}
+
+// disallow takes back a user's way into a hidden room, for their account
+// and for every guest session going by the name
+func (h *Hub) disallow(state *RoomState, username string) {
+	state.disallow(username)
+	canonical := canonicalName(username)
+	for client := range h.clients {
+		if canonicalName(client.Username) == canonical {
+			delete(state.guests, client.resume)
+		}
+	}
+}
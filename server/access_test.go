@@ -0,0 +1,103 @@
+package server
+
+import (
+	"strings"
+	"terminal-chat/models"
+	"testing"
+)
+
+// setAccess sends an access change for room
+func (c *testConn) setAccess(room, action, key, user string) {
+	c.t.Helper()
+	msg := models.NewMessage(models.MessageTypeAccess, "", "", room)
+	msg.Action, msg.Key, msg.User = action, key, user
+	c.send(msg)
+}
+
+// join asks to enter room with key
+func (c *testConn) join(room, key string) {
+	c.t.Helper()
+	msg := models.NewMessage(models.MessageTypeJoinRoom, "", "", room)
+	msg.Key = key
+	c.send(msg)
+}
+
+// refused waits for the error that turns away a join
+func (c *testConn) refused(code string) {
+	c.t.Helper()
+	if got := c.next(models.MessageTypeError); got.Code != code {
+		c.t.Fatalf("got error %q (%s), want %q", got.Code, got.Content, code)
+	}
+}
+
+func TestInviteOnlyRoom(t *testing.T) {
+	srv := newTestServer(t, DefaultConfig())
+	owner := srv.dialOwner(t, "owner", "secret")
+	owner.setAccess("secret", models.AccessPrivate, "", "")
+	owner.nextWhere(models.MessageTypeSystem, func(msg models.Message) bool { return msg.Room == "secret" })
+
+	guest := srv.dial(t, "username=dave&room=lobby&resume=dave-session")
+	guest.joined("lobby")
+	guest.join("secret", "")
+	guest.refused(models.ErrCodeInviteOnly)
+
+	owner.setAccess("secret", models.AccessInvite, "", "")
+	notice := owner.nextWhere(models.MessageTypeSystem, func(msg models.Message) bool {
+		return strings.Contains(msg.Content, "/join secret ")
+	})
+	token := notice.Content[strings.LastIndex(notice.Content, " ")+1:]
+
+	guest.join("secret", token)
+	guest.joined("secret")
+
+	// Tokens are good for one user
+	other := srv.dial(t, "username=erin&room=lobby")
+	other.joined("lobby")
+	other.join("secret", token)
+	other.refused(models.ErrCodeInviteOnly)
+
+	// The guest's session gets back in without a key
+	back := srv.dial(t, "username=dave&room=secret&resume=dave-session")
+	back.joined("secret")
+
+	// Someone else going by the same name does not
+	impostor := srv.dial(t, "username=dave&room=lobby&resume=another-session")
+	impostor.joined("lobby")
+	impostor.join("secret", "")
+	impostor.refused(models.ErrCodeInviteOnly)
+}
+
+func TestInvitesByNameNeedAnAccount(t *testing.T) {
+	srv := newTestServer(t, DefaultConfig())
+	owner := srv.dialOwner(t, "owner", "secret")
+	owner.setAccess("secret", models.AccessPrivate, "", "")
+
+	owner.setAccess("secret", models.AccessInvite, "", "dave")
+	if got := owner.next(models.MessageTypeError); got.Code != models.ErrCodeNotFound {
+		t.Fatalf("a guest name was invited: %+v", got)
+	}
+
+	token := srv.register(t, "Frank")
+	owner.setAccess("secret", models.AccessInvite, "", "frank")
+	owner.nextWhere(models.MessageTypeSystem, func(msg models.Message) bool {
+		return strings.Contains(msg.Content, "invited Frank")
+	})
+	frank := srv.dial(t, "username=Frank&room=secret&token="+token)
+	frank.joined("secret")
+}
+
+func TestPassphraseRoom(t *testing.T) {
+	srv := newTestServer(t, DefaultConfig())
+	owner := srv.dialOwner(t, "owner", "club")
+	owner.setAccess("club", models.AccessPassphrase, "open sesame", "")
+	owner.nextWhere(models.MessageTypeSystem, func(msg models.Message) bool { return msg.Room == "club" })
+
+	guest := srv.dial(t, "username=gina&room=lobby")
+	guest.joined("lobby")
+	guest.join("club", "")
+	guest.refused(models.ErrCodePassphrase)
+	guest.join("club", "open says me")
+	guest.refused(models.ErrCodePassphrase)
+	guest.join("club", "open sesame")
+	guest.joined("club")
+}
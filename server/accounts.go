@@ -0,0 +1,243 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	minPasswordLength = 8
+	maxUsernameLength = 20
+)
+
+// passwordCost is the bcrypt cost passwords are hashed at
+var passwordCost = bcrypt.DefaultCost
+
+// Account errors returned to the HTTP API
+var (
+	ErrAccountExists   = errors.New("username is already registered")
+	ErrUnknownAccount  = errors.New("no account with that username")
+	ErrBadCredentials  = errors.New("wrong username or password")
+	ErrWeakPassword    = errors.New("password must be at least 8 characters")
+	ErrInvalidUsername = errors.New("username must be 1-20 characters")
+	ErrPasswordTooLong = errors.New("password is too long")
+	ErrInvalidSession  = errors.New("invalid or expired session token")
+)
+
+// Account is a registered user as stored on disk
+type Account struct {
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"password_hash"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type session struct {
+	username string
+	expires  time.Time
+}
+
+// AccountStore keeps registered accounts in a JSON file and the login
+// sessions issued for them in memory
+type AccountStore struct {
+	mu         sync.Mutex
+	path       string
+	accounts   map[string]*Account
+	sessions   map[string]session
+	sessionTTL time.Duration
+}
+
+// NewAccountStore loads the accounts file, creating it on first use
+func NewAccountStore(path string, sessionTTL time.Duration) (*AccountStore, error) {
+	s := &AccountStore{
+		path:       path,
+		accounts:   make(map[string]*Account),
+		sessions:   make(map[string]session),
+		sessionTTL: sessionTTL,
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var accounts []*Account
+	if err := json.Unmarshal(data, &accounts); err != nil {
+		return nil, err
+	}
+	for _, account := range accounts {
+		s.accounts[account.Username] = account
+	}
+	return s, nil
+}
+
+// Register creates an account with a bcrypt-hashed password
+func (s *AccountStore) Register(username, password string) error {
+	if err := validateUsername(username); err != nil {
+		return err
+	}
+	if len(password) < minPasswordLength {
+		return ErrWeakPassword
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost)
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return ErrPasswordTooLong
+	}
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.holder(username); exists {
+		return ErrAccountExists
+	}
+	s.accounts[username] = &Account{
+		Username:     username,
+		PasswordHash: string(hash),
+		CreatedAt:    time.Now(),
+	}
+
+	if err := s.save(); err != nil {
+		delete(s.accounts, username)
+		return err
+	}
+	return nil
+}
+
+// Login checks a password and issues a session token
+func (s *AccountStore) Login(username, password string) (string, time.Time, error) {
+	s.mu.Lock()
+	account, exists := s.accounts[username]
+	s.mu.Unlock()
+
+	if !exists {
+		return "", time.Time{}, ErrUnknownAccount
+	}
+	if bcrypt.CompareHashAndPassword([]byte(account.PasswordHash), []byte(password)) != nil {
+		return "", time.Time{}, ErrBadCredentials
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expires := time.Now().Add(s.sessionTTL)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pruneSessions()
+	s.sessions[token] = session{username: username, expires: expires}
+	return token, expires, nil
+}
+
+// Registered reports whether a username belongs to an account
+func (s *AccountStore) Registered(username string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, exists := s.accounts[username]
+	return exists
+}
+
+// Holder returns the registered name that matches username in case and
+// Unicode form, since no two accounts may differ only in those
+func (s *AccountStore) Holder(username string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.holder(username)
+}
+
+// holder is Holder for callers that hold s.mu
+func (s *AccountStore) holder(username string) (string, bool) {
+	if _, exists := s.accounts[username]; exists {
+		return username, true
+	}
+	canonical := canonicalName(username)
+	for name := range s.accounts {
+		if canonicalName(name) == canonical {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// Authenticate checks that a token is a live session for the username
+func (s *AccountStore) Authenticate(username, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[token]
+	if !ok || token == "" {
+		return ErrInvalidSession
+	}
+	if time.Now().After(sess.expires) {
+		delete(s.sessions, token)
+		return ErrInvalidSession
+	}
+	if sess.username != username {
+		return ErrBadCredentials
+	}
+	return nil
+}
+
+// pruneSessions forgets expired sessions. Callers hold s.mu.
+func (s *AccountStore) pruneSessions() {
+	now := time.Now()
+	for token, sess := range s.sessions {
+		if now.After(sess.expires) {
+			delete(s.sessions, token)
+		}
+	}
+}
+
+// save writes all accounts atomically. Callers hold s.mu.
+func (s *AccountStore) save() error {
+	accounts := make([]*Account, 0, len(s.accounts))
+	for _, account := range s.accounts {
+		accounts = append(accounts, account)
+	}
+
+	data, err := json.MarshalIndent(accounts, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data, 0o600)
+}
+
+// writeFileAtomic replaces a file through a temporary file and a rename,
+// so a crash never leaves half-written state behind
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// newToken returns a random 256-bit hex token
+func newToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
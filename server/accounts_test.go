@@ -0,0 +1,156 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"terminal-chat/models"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// post sends credentials to an account endpoint of the server
+func (s *testServer) post(t *testing.T, path, username, password string) *http.Response {
+	t.Helper()
+	body, _ := json.Marshal(models.Credentials{Username: username, Password: password})
+	resp, err := http.Post(s.URL+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// dialStatus tries to connect and returns the HTTP status of a refusal,
+// or 101 if the connection was accepted
+func (s *testServer) dialStatus(t *testing.T, query string) int {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(s.URL, "http") + "/ws?" + query
+	conn, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err == nil {
+		conn.Close()
+		return http.StatusSwitchingProtocols
+	}
+	if resp == nil {
+		t.Fatalf("dial %s: %v", query, err)
+	}
+	return resp.StatusCode
+}
+
+func TestRegisterThenLogIn(t *testing.T) {
+	srv := newTestServer(t, DefaultConfig())
+
+	if resp := srv.post(t, "/register", "Alice", "password1"); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("register: status %d", resp.StatusCode)
+	}
+	if resp := srv.post(t, "/login", "Alice", "wrong-password"); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("login with the wrong password: status %d", resp.StatusCode)
+	}
+	resp := srv.post(t, "/login", "Alice", "password1")
+	var session models.Session
+	if err := json.NewDecoder(resp.Body).Decode(&session); err != nil || session.Token == "" {
+		t.Fatalf("login: status %d, %+v, %v", resp.StatusCode, session, err)
+	}
+
+	alice := srv.dial(t, "username=Alice&room=r&token="+session.Token)
+	if got := alice.next(models.MessageTypeWelcome).Username; got != "Alice" {
+		t.Errorf("connected as %q, want Alice", got)
+	}
+
+	// Accounts are kept on disk
+	reopened, err := NewAccountStore(filepath.Join(srv.dir, "accounts.json"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := reopened.Login("Alice", "password1"); err != nil {
+		t.Errorf("login after reopening the accounts: %v", err)
+	}
+}
+
+func TestRegisterRefusesTakenAndWeakNames(t *testing.T) {
+	accounts, err := NewAccountStore(filepath.Join(t.TempDir(), "accounts.json"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := accounts.Register("Alice", "password1"); err != nil {
+		t.Fatal(err)
+	}
+
+	for username, want := range map[string]error{
+		"Alice":  ErrAccountExists,
+		"alice":  ErrAccountExists, // Case folded
+		"ＡＬＩＣＥ":  ErrAccountExists, // Full-width
+		"system": ErrReservedName,
+		"a b":    ErrNameSpaces,
+	} {
+		if err := accounts.Register(username, "password2"); err != want {
+			t.Errorf("Register(%q) = %v, want %v", username, err, want)
+		}
+	}
+	if err := accounts.Register("bob", "short"); err != ErrWeakPassword {
+		t.Errorf("a short password gave %v, want %v", err, ErrWeakPassword)
+	}
+	if _, _, err := accounts.Login("alice", "password1"); err != ErrUnknownAccount {
+		t.Errorf("login under another case gave %v; tokens are for the exact name", err)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	accounts, err := NewAccountStore(filepath.Join(t.TempDir(), "accounts.json"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"alice", "bob"} {
+		if err := accounts.Register(name, "password1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	aliceToken, _, _ := accounts.Login("alice", "password1")
+	bobToken, _, _ := accounts.Login("bob", "password1")
+
+	if err := accounts.Authenticate("alice", aliceToken); err != nil {
+		t.Errorf("alice's own token: %v", err)
+	}
+	if err := accounts.Authenticate("alice", bobToken); err != ErrBadCredentials {
+		t.Errorf("bob's token for alice: %v, want %v", err, ErrBadCredentials)
+	}
+	if err := accounts.Authenticate("alice", ""); err != ErrInvalidSession {
+		t.Errorf("no token: %v, want %v", err, ErrInvalidSession)
+	}
+
+	accounts.sessionTTL = time.Millisecond
+	expiring, _, _ := accounts.Login("alice", "password1")
+	time.Sleep(5 * time.Millisecond)
+	if err := accounts.Authenticate("alice", expiring); err != ErrInvalidSession {
+		t.Errorf("expired token: %v, want %v", err, ErrInvalidSession)
+	}
+}
+
+func TestServeWSWantsAValidToken(t *testing.T) {
+	cfg := DefaultConfig()
+	srv := newTestServer(t, cfg)
+	carolToken := srv.register(t, "Carol")
+	srv.register(t, "Dave")
+
+	for query, want := range map[string]int{
+		"username=Dave&room=r":                      http.StatusUnauthorized, // No token
+		"username=Dave&room=r&token=" + carolToken:  http.StatusUnauthorized, // Carol's
+		"username=Dave&room=r&token=0123abcd":       http.StatusUnauthorized, // Never issued
+		"username=Carol&room=r&token=" + carolToken: http.StatusSwitchingProtocols,
+		"username=guest1&room=r":                    http.StatusSwitchingProtocols,
+	} {
+		if got := srv.dialStatus(t, query); got != want {
+			t.Errorf("%s: status %d, want %d", query, got, want)
+		}
+	}
+
+	// Servers that require login turn guests away
+	cfg.RequireLogin = true
+	if got := srv.dialStatus(t, "username=guest2&room=r"); got != http.StatusUnauthorized {
+		t.Errorf("a guest on a server requiring login: status %d, want %d", got, http.StatusUnauthorized)
+	}
+}
@@ -2,9 +2,17 @@ package server
 
 import (
 	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"terminal-chat/models"
 	"time"
 
@@ -13,8 +21,6 @@ import (
 
 const (
 	writeWait      = 30 * time.Second
-	pongWait       = 0
-	pingPeriod     = 25 * time.Second
 	maxMessageSize = 512
 )
 
@@ -33,66 +39,162 @@ var upgrader = websocket.Upgrader{
 
 // Client represents a WebSocket client
 type Client struct {
-	hub      *Hub
-	conn     *websocket.Conn
-	send     chan []byte
-	Username string
-	Room     string
+	hub       *Hub
+	conn      *websocket.Conn
+	send      chan []byte
+	downloads chan string // IDs of files queued for writePump to stream
+	Username  string
+	JoinedAt  time.Time
+	ip        string // Remote address, for IP bans
+
+	registered bool          // Username belongs to an account this connection logged in to
+	resume     string        // Secret the client presents again when it reconnects
+	session    string        // Random ID standing in for resume when the client sends none
+	named      chan struct{} // Closed by the hub once Username is settled; fixed from then on
+
+	initialRoom  string          // Room requested in the connection URL
+	initialSince uint64          // Last seq seen in initialRoom before a reconnect
+	rooms        map[string]bool // Rooms this connection sits in, owned by the hub
+	frames       bucket          // Frame rate limit, owned by the hub's limiter
+
+	// Close frame sent once send is closed; set by the hub before closing it
+	closeCode   int
+	closeReason string
+
+	// Why the connection ended, reported in leave messages; set by readPump
+	leaveReason string
 }
 
 // readPump pumps messages from the websocket connection to the hub
 func (c *Client) readPump() {
+	c.hub.limiter.open(c.Username)
 	defer func() {
-		log.Printf("Client %s disconnecting from room %s", c.Username, c.Room)
-		c.hub.unregister <- c
+		log.Printf("Client %s disconnecting", c.Username)
+		c.hub.limiter.close(c.Username)
+		c.hub.files.Abort(c)
+		select {
+		case c.hub.unregister <- c:
+		case <-c.hub.done:
+		}
 		c.conn.Close()
 	}()
 
-	c.conn.SetReadLimit(maxMessageSize)
-	// Remove all read deadlines - let connection stay alive indefinitely
-	// c.conn.SetReadDeadline(time.Now().Add(pongWait)) // REMOVE THIS
+	pongWait := c.hub.cfg.PongWait
+	c.conn.SetReadLimit(maxFrameSize)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
 
-	// Optional: Keep pong handler for heartbeat but without deadline
+	// Every pong proves the peer is alive and pushes the deadline out
 	c.conn.SetPongHandler(func(string) error {
-		log.Printf("📡 Received pong from client %s", c.Username)
+		c.conn.SetReadDeadline(time.Now().Add(pongWait))
 		return nil
 	})
 
 	for {
-		_, message, err := c.conn.ReadMessage()
+		kind, message, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("Unexpected close error for %s: %v", c.Username, err)
 			}
+			c.leaveReason = disconnectReason(err)
+			if c.leaveReason == reasonPingTimeout {
+				log.Printf("💀 %s missed its pongs for %s, evicting", c.Username, pongWait)
+			}
 			break
 		}
 
+		if kind == websocket.BinaryMessage {
+			// File data skips the frame limit, its upload was approved already
+			if !c.receiveChunk(message) && c.hub.limiter.check(c, "") == kicked {
+				c.kick(websocket.ClosePolicyViolation, "sending too fast", reasonFlooding)
+				return
+			}
+			continue
+		}
+		if len(message) > maxMessageSize {
+			c.kick(websocket.CloseMessageTooBig, "message too big", reasonConnLost)
+			return
+		}
+
 		message = bytes.TrimSpace(bytes.Replace(message, newline, space, -1))
 
+		// Limits are enforced here, so a flood never reaches the hub
 		var msg models.Message
-		if err := json.Unmarshal(message, &msg); err == nil {
-			log.Printf("💬 [%s] %s: %s", msg.Room, msg.Username, msg.Content)
+		json.Unmarshal(message, &msg)
+		verdict := c.hub.limiter.check(c, msg.Type)
+		switch verdict {
+		case allowed:
+			c.hub.debugf("💬 [%s] %s: %s", msg.Room, c.Username, msg.Content)
+		case kicked:
+			// A policy close also stops the client from reconnecting
+			c.kick(websocket.ClosePolicyViolation, "sending too fast", reasonFlooding)
+			return
+		case dropped:
+			if msg.ClientID == "" {
+				continue // Nothing the client needs to hear about
+			}
 		}
 
-		c.hub.broadcast <- message
+		select {
+		case c.hub.broadcast <- &envelope{client: c, data: message, verdict: verdict}:
+		case <-c.hub.done:
+			return
+		}
 	}
 }
 
-// writePump pumps messages from the hub to the websocket connection
+// kick ends the connection from readPump, writing the close frame itself
+// since the hub's writePump may not get to it before the socket closes
+func (c *Client) kick(code int, text, leaveReason string) {
+	c.leaveReason = leaveReason
+	c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text),
+		time.Now().Add(writeWait))
+}
+
+// author is who the client's messages are stored as written by: the account
+// for registered users, else the guest session. Guest sessions are known by
+// a hash of their secret, so history never holds the secret itself.
+func (c *Client) author() string {
+	if c.registered {
+		return "account:" + canonicalName(c.Username)
+	}
+	secret := c.resume
+	if secret == "" {
+		secret = c.session
+	}
+	sum := sha256.Sum256([]byte(secret))
+	return "guest:" + hex.EncodeToString(sum[:])
+}
+
+// writePump pumps messages from the hub to the websocket connection, and
+// streams queued downloads in between without holding them up
 func (c *Client) writePump() {
-	// Optional: Remove ticker for ping if you don't want heartbeat
-	// ticker := time.NewTicker(pingPeriod)
+	ticker := time.NewTicker(c.hub.cfg.PingPeriod)
+	var current *download
 	defer func() {
-		// ticker.Stop()
+		ticker.Stop()
+		if current != nil {
+			current.in.Close()
+		}
 		c.conn.Close()
+		c.hub.writers.Done()
 	}()
 
 	for {
+		// One download at a time, its chunks sent whenever nothing else waits
+		downloads, nextChunk := c.downloads, (<-chan struct{})(nil)
+		if current != nil {
+			downloads, nextChunk = nil, ready
+		}
+
 		select {
 		case message, ok := <-c.send:
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if !ok {
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				closeMsg := []byte{}
+				if c.closeCode != 0 {
+					closeMsg = websocket.FormatCloseMessage(c.closeCode, c.closeReason)
+				}
+				c.conn.WriteMessage(websocket.CloseMessage, closeMsg)
 				return
 			}
 
@@ -102,44 +204,147 @@ func (c *Client) writePump() {
 				return
 			}
 
-			// Remove automatic ping - only disconnect when client chooses to
-			// case <-ticker.C:
-			//     c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			//     if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-			//         return
-			//     }
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+
+		case id := <-downloads:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			var err error
+			if current, err = c.startDownload(id); err != nil {
+				return
+			}
+
+		case <-nextChunk:
+			more, err := current.sendChunk(c.conn)
+			if err != nil {
+				current = nil
+				return
+			}
+			if !more {
+				current = nil
+			}
 		}
 	}
 }
 
-// ServeWS handles websocket requests from clients
-func ServeWS(hub *Hub, w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Println(err)
-		return
+// Leave reasons reported when a connection ends without a clean close
+const (
+	reasonPingTimeout = "ping timeout"
+	reasonConnLost    = "connection lost"
+	reasonFlooding    = "disconnected for flooding"
+)
+
+// disconnectReason explains a read error for the leave message; a clean
+// close by the client needs no explanation
+func disconnectReason(err error) string {
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) {
+		if closeErr.Code == websocket.CloseNormalClosure || closeErr.Code == websocket.CloseGoingAway {
+			return ""
+		}
+		return reasonConnLost
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return reasonPingTimeout
 	}
+	return reasonConnLost
+}
 
-	username := r.URL.Query().Get("username")
+// ServeWS handles websocket requests from clients
+func ServeWS(hub *Hub, accounts *AccountStore, w http.ResponseWriter, r *http.Request) {
+	username := strings.TrimSpace(r.URL.Query().Get("username"))
 	room := r.URL.Query().Get("room")
+	since, _ := strconv.ParseUint(r.URL.Query().Get("since"), 10, 64)
 
 	if username == "" {
-		username = "Anonymous"
+		username = guestName
 	}
 	if room == "" {
 		room = "general"
 	}
 
+	// Refuse before upgrading so the client sees a plain status and reason
+	if err := validateUsername(username); err != nil {
+		log.Printf("🔒 Refused connection as %q: %v", username, err)
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrReservedName) {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if err := authorize(hub.cfg, accounts, username, r); err != nil {
+		log.Printf("🔒 Refused connection as %s: %v", username, err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	ip, _, _ := net.SplitHostPort(r.RemoteAddr)
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
 	client := &Client{
-		hub:      hub,
-		conn:     conn,
-		send:     make(chan []byte, 256),
-		Username: username,
-		Room:     room,
+		hub:       hub,
+		conn:      conn,
+		send:      make(chan []byte, sendQueueSize),
+		downloads: make(chan string, maxDownloads),
+		Username:  username,
+		ip:        ip,
+
+		registered: accounts.Registered(username),
+		resume:     r.URL.Query().Get("resume"),
+		session:    rand.Text(),
+		named:      make(chan struct{}),
+
+		initialRoom:  room,
+		initialSince: since,
+	}
+
+	client.hub.writers.Add(1)
+	select {
+	case client.hub.register <- client:
+	case <-client.hub.done:
+		// Upgraded just as the server began shutting down
+		client.hub.writers.Done()
+		conn.WriteMessage(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"))
+		conn.Close()
+		return
 	}
 
-	client.hub.register <- client
+	// The hub may rename the connection; the pumps start once it has, so
+	// they never see the name change
+	<-client.named
 
 	go client.writePump()
 	go client.readPump()
 }
+
+// authorize requires a valid session token for registered usernames, and
+// for everyone when the server only admits registered accounts
+func authorize(cfg *Config, accounts *AccountStore, username string, r *http.Request) error {
+	token := r.URL.Query().Get("token")
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		token = bearer
+	}
+
+	if holder, ok := accounts.Holder(username); ok {
+		if holder != username {
+			return fmt.Errorf("%s is registered as %s", username, holder)
+		}
+		return accounts.Authenticate(username, token)
+	}
+	if cfg.RequireLogin {
+		return fmt.Errorf("this server requires a registered account")
+	}
+	return nil
+}
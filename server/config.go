@@ -0,0 +1,131 @@
+package server
+
+import (
+	"flag"
+	"fmt"
+	"time"
+)
+
+// Config holds the server settings
+type Config struct {
+	Port           string
+	DataDir        string // Directory for persistent server state
+	HistoryBackend string // "jsonl", "bolt" or "memory"
+	HistoryLimit   int    // Messages replayed to a client when it joins a room
+
+	RequireLogin bool          // Refuse guests that have no registered account
+	SessionTTL   time.Duration // Lifetime of login session tokens
+
+	TLSCert       string // PEM certificate file for wss://
+	TLSKey        string // PEM private key file for wss://
+	TLSSelfSigned bool   // Generate a self-signed certificate when none is given
+
+	ShutdownTimeout time.Duration // Deadline for flushing clients on shutdown
+
+	MOTD string // Message of the day shown to clients when they connect
+
+	Debug bool // Log every message and delivery, content included
+
+	PingPeriod time.Duration // How often the server pings each client
+	PongWait   time.Duration // Silence after which a client is considered dead
+
+	FrameLimit   RateLimit     // Frames of any kind per connection; exceeding it disconnects
+	MessageLimit RateLimit     // Messages, edits and reactions per user
+	GIFLimit     RateLimit     // GIFs per user
+	JoinLimit    RateLimit     // Room joins and parts per user
+	MuteAfter    int           // Breaches within a minute that earn a mute
+	MuteDuration time.Duration // How long a mute lasts
+
+	MaxFileSize   ByteSize      // Largest file users may share
+	FileStoreSize ByteSize      // Space for shared files, uploads included
+	FileTTL       time.Duration // How long shared files can be downloaded
+}
+
+// DefaultConfig returns the settings used when no flags are given
+func DefaultConfig() *Config {
+	return &Config{
+		Port:           "8080",
+		DataDir:        "chat-data",
+		HistoryBackend: "jsonl",
+		HistoryLimit:   50,
+
+		SessionTTL: 24 * time.Hour,
+
+		ShutdownTimeout: 10 * time.Second,
+
+		PingPeriod: 25 * time.Second,
+		PongWait:   60 * time.Second,
+
+		// Frames allow a reconnecting client to flush a full outbox at once
+		FrameLimit:   RateLimit{Rate: 20, Burst: 150},
+		MessageLimit: RateLimit{Rate: 1, Burst: 8},
+		GIFLimit:     RateLimit{Rate: 0.2, Burst: 2},
+		JoinLimit:    RateLimit{Rate: 0.5, Burst: 5},
+		MuteAfter:    3,
+		MuteDuration: 30 * time.Second,
+
+		MaxFileSize:   10 << 20,
+		FileStoreSize: 256 << 20,
+		FileTTL:       time.Hour,
+	}
+}
+
+// BindFlags registers the server settings on a flag set.
+// The port flag is left to the caller since the combined binary shares it with the client.
+func (c *Config) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.DataDir, "data-dir", c.DataDir, "Directory for persistent server state")
+	fs.StringVar(&c.HistoryBackend, "history", c.HistoryBackend, "Message history backend: jsonl, bolt or memory")
+	fs.IntVar(&c.HistoryLimit, "history-limit", c.HistoryLimit, "Messages replayed to clients when they join a room")
+	fs.BoolVar(&c.RequireLogin, "require-login", c.RequireLogin, "Only allow registered accounts to connect")
+	fs.DurationVar(&c.SessionTTL, "session-ttl", c.SessionTTL, "How long login session tokens stay valid")
+	fs.StringVar(&c.TLSCert, "tls-cert", c.TLSCert, "TLS certificate file (enables wss://)")
+	fs.StringVar(&c.TLSKey, "tls-key", c.TLSKey, "TLS private key file")
+	fs.BoolVar(&c.TLSSelfSigned, "tls-self-signed", c.TLSSelfSigned, "Serve wss:// with a generated self-signed certificate (LAN use)")
+	fs.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", c.ShutdownTimeout, "How long to wait for clients to be flushed on shutdown")
+	fs.StringVar(&c.MOTD, "motd", c.MOTD, "Message of the day shown to users when they connect")
+	fs.BoolVar(&c.Debug, "debug", c.Debug, "Log every message and delivery, including what users write")
+	fs.DurationVar(&c.PingPeriod, "ping-period", c.PingPeriod, "How often clients are pinged")
+	fs.DurationVar(&c.PongWait, "pong-wait", c.PongWait, "Disconnect clients that stay silent this long")
+	fs.Var(&c.FrameLimit, "frame-limit", "Frames per second/burst per connection before it is disconnected")
+	fs.Var(&c.MessageLimit, "message-limit", "Messages per second/burst per user")
+	fs.Var(&c.GIFLimit, "gif-limit", "GIFs per second/burst per user")
+	fs.Var(&c.JoinLimit, "join-limit", "Room joins and parts per second/burst per user")
+	fs.IntVar(&c.MuteAfter, "mute-after", c.MuteAfter, "Rate limit breaches within a minute that mute a user")
+	fs.DurationVar(&c.MuteDuration, "mute-duration", c.MuteDuration, "How long flooding users stay muted")
+	fs.Var(&c.MaxFileSize, "max-file-size", "Largest file users may share, e.g. 10MB")
+	fs.Var(&c.FileStoreSize, "file-store-size", "Disk space for shared files, e.g. 256MB")
+	fs.DurationVar(&c.FileTTL, "file-ttl", c.FileTTL, "How long shared files stay available")
+}
+
+// Validate checks settings that depend on each other
+func (c *Config) Validate() error {
+	if c.PingPeriod <= 0 || c.PingPeriod >= c.PongWait {
+		return fmt.Errorf("-ping-period (%s) must be positive and shorter than -pong-wait (%s)",
+			c.PingPeriod, c.PongWait)
+	}
+	limits := []struct {
+		name  string
+		limit RateLimit
+	}{
+		{"frame-limit", c.FrameLimit},
+		{"message-limit", c.MessageLimit},
+		{"gif-limit", c.GIFLimit},
+		{"join-limit", c.JoinLimit},
+	}
+	for _, l := range limits {
+		if err := l.limit.validate(l.name); err != nil {
+			return err
+		}
+	}
+	if c.MuteAfter < 1 {
+		return fmt.Errorf("-mute-after (%d) must be at least 1", c.MuteAfter)
+	}
+	if c.MaxFileSize <= 0 || c.MaxFileSize > c.FileStoreSize {
+		return fmt.Errorf("-max-file-size (%s) must be positive and fit in -file-store-size (%s)",
+			c.MaxFileSize, c.FileStoreSize)
+	}
+	if c.FileTTL <= 0 {
+		return fmt.Errorf("-file-ttl (%s) must be positive", c.FileTTL)
+	}
+	return nil
+}
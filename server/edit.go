@@ -0,0 +1,93 @@
+package server
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"terminal-chat/models"
+	"time"
+)
+
+// changeMessage applies an edit or delete to a stored room message and
+// tells the room, so every client can update the line it already shows
+func (h *Hub) changeMessage(client *Client, msg *models.Message) {
+	room, ok := h.resolveRoom(client, msg.Room)
+	if !ok {
+		h.rejectMessage(client, msg, models.ErrCodeWrongRoom,
+			fmt.Sprintf("You are not a member of room '%s'", msg.Room))
+		return
+	}
+	// A muted user may still delete, but not say anything new
+	if msg.Type == models.MessageTypeEdit && !h.checkPosting(client, msg, room) {
+		return
+	}
+
+	target, err := h.store.Find(room, msg.Target)
+	if errors.Is(err, ErrMessageNotFound) || (err == nil && target.Deleted) {
+		h.rejectMessage(client, msg, models.ErrCodeNotFound, "That message no longer exists")
+		return
+	}
+	if err != nil {
+		log.Printf("⚠️ Failed to look up message %s in room '%s': %v", msg.Target, room, err)
+		h.rejectMessage(client, msg, models.ErrCodeNotFound, "That message could not be loaded")
+		return
+	}
+
+	// Authors may change their own messages; moderators may only remove others'.
+	// Authorship goes by account or guest session, not by the name, which a
+	// later guest may have taken.
+	own := target.Author != "" && target.Author == client.author()
+	if !own && (msg.Type == models.MessageTypeEdit || !h.isModerator(client, room)) {
+		log.Printf("🚫 %s tried to %s a message by %s", client.Username, msg.Type, target.Username)
+		h.rejectMessage(client, msg, models.ErrCodeNotAllowed,
+			fmt.Sprintf("You cannot %s messages sent by %s", msg.Type, target.Username))
+		return
+	}
+
+	event := models.NewMessage(msg.Type, client.Username, "", room)
+	event.ID = rand.Text()
+	event.ClientID = msg.ClientID
+	event.Target = target.ID
+
+	switch msg.Type {
+	case models.MessageTypeEdit:
+		content := strings.TrimSpace(msg.Content)
+		if content == "" {
+			h.rejectMessage(client, msg, models.ErrCodeBadMessage, "Use /delete to remove a message")
+			return
+		}
+		if target.Type != models.MessageTypeChat {
+			h.rejectMessage(client, msg, models.ErrCodeNotAllowed, "Only text messages can be edited")
+			return
+		}
+		target.Content = content
+		target.Edited = true
+		event.Content = content
+
+	case models.MessageTypeDelete:
+		target.Content = ""
+		target.GIFName = ""
+		target.IsGIF = false
+		target.Reactions = nil
+		target.Deleted = true
+	}
+
+	if err := h.store.Update(target); err != nil {
+		log.Printf("⚠️ Failed to store %s of message %s: %v", msg.Type, target.ID, err)
+		h.rejectMessage(client, msg, models.ErrCodeNotFound, "That message could not be changed")
+		return
+	}
+
+	log.Printf("✏️ %s applied %s to message %s in room '%s'", client.Username, msg.Type, target.ID, room)
+	event.Timestamp = time.Now()
+	h.broadcastToRoom(event, room)
+	h.acknowledge(client, event)
+}
+
+// isModerator reports whether a client may remove other people's messages
+// in a room: its owner and moderators may
+func (h *Hub) isModerator(client *Client, room string) bool {
+	return h.role(room, client) != models.RoleMember
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"terminal-chat/models"
+	"testing"
+)
+
+// edit asks to replace the text of message target in room r
+func (c *testConn) edit(target, content string) {
+	c.t.Helper()
+	msg := models.NewMessage(models.MessageTypeEdit, "", content, "r")
+	msg.Target, msg.ClientID = target, content
+	c.send(msg)
+}
+
+func TestEditsFollowTheAuthorNotTheName(t *testing.T) {
+	srv := newTestServer(t, DefaultConfig())
+	bob := srv.dial(t, "username=bob&room=r")
+	bob.joined("r")
+
+	alice := srv.dial(t, "username=alice&room=r&resume=first-session")
+	alice.joined("r")
+	alice.chat("r", "hello")
+	sent := alice.next(models.MessageTypeChat)
+	if sent.Author != "" {
+		t.Errorf("the author %q was sent to clients", sent.Author)
+	}
+	alice.conn.Close()
+	bob.nextWhere(models.MessageTypeLeave, func(msg models.Message) bool { return msg.Username == "alice" })
+
+	impostor := srv.dial(t, "username=alice&room=r&resume=other-session")
+	impostor.joined("r")
+	impostor.edit(sent.ID, "not what alice said")
+	if got := impostor.next(models.MessageTypeError); got.Code != models.ErrCodeNotAllowed {
+		t.Errorf("a later guest under the same name edited the message: %+v", got)
+	}
+
+	// The first session keeps its messages, even under another name
+	back := srv.dial(t, "username=alice&room=r&resume=first-session")
+	back.joined("r")
+	back.edit(sent.ID, "hello again")
+	if got := back.next(models.MessageTypeEdit); got.Content != "hello again" {
+		t.Errorf("edit by the author's session gave %+v", got)
+	}
+}
+
+func TestAccountsKeepTheirMessagesAcrossSessions(t *testing.T) {
+	srv := newTestServer(t, DefaultConfig())
+	token := srv.register(t, "Carol")
+	carol := srv.dial(t, "username=Carol&room=r&resume=one&token="+token)
+	carol.joined("r")
+	carol.chat("r", "hello")
+	sent := carol.next(models.MessageTypeChat)
+	carol.conn.Close()
+
+	carol = srv.dial(t, "username=Carol&room=r&resume=two&token="+token)
+	carol.joined("r")
+	carol.edit(sent.ID, "hello again")
+	if got := carol.next(models.MessageTypeEdit); got.Content != "hello again" {
+		t.Errorf("edit by the account in a new session gave %+v", got)
+	}
+}
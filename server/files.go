@@ -0,0 +1,255 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"terminal-chat/models"
+	"time"
+	"unicode"
+)
+
+// File store errors, shown to the uploader or downloader
+var (
+	ErrFileNotFound  = errors.New("no such file, or it has expired")
+	ErrFileStoreFull = errors.New("the server has no room for more files right now")
+	ErrFileTooLong   = errors.New("more data arrived than the file's size")
+	ErrChecksum      = errors.New("the file's checksum does not match")
+)
+
+// ByteSize is a size in bytes that flags accept as e.g. 512KB or 10MB
+type ByteSize int64
+
+// String renders a size with the largest unit that divides it
+func (b ByteSize) String() string {
+	for _, unit := range []struct {
+		suffix string
+		size   ByteSize
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}} {
+		if b >= unit.size && b%unit.size == 0 {
+			return fmt.Sprintf("%d%s", b/unit.size, unit.suffix)
+		}
+	}
+	return strconv.FormatInt(int64(b), 10)
+}
+
+// Set parses a flag value such as 10MB; plain numbers are bytes
+func (b *ByteSize) Set(value string) error {
+	number, scale := strings.ToUpper(strings.TrimSpace(value)), ByteSize(1)
+	for suffix, size := range map[string]ByteSize{"GB": 1 << 30, "MB": 1 << 20, "KB": 1 << 10} {
+		if trimmed, ok := strings.CutSuffix(number, suffix); ok {
+			number, scale = trimmed, size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(number), 10, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("want a size such as 512KB or 10MB")
+	}
+	*b = ByteSize(n) * scale
+	return nil
+}
+
+// storedFile is a file shared in a room, or one still being uploaded.
+// Everything but the upload state is fixed once the file is complete.
+type storedFile struct {
+	models.File
+	room     string
+	clientID string    // Correlation ID of the offer, echoed to the uploader
+	expires  time.Time // Removed after this; uploads must finish by then too
+
+	owner    *Client  // Uploading connection, nil once complete
+	out      *os.File // Open while uploading
+	hash     hash.Hash
+	received int64
+}
+
+// FileStore keeps shared files on disk for a while. Uploads are written by
+// readPumps, downloads read by writePumps and the hub looks files up, so it
+// has a lock of its own.
+type FileStore struct {
+	mu    sync.Mutex
+	dir   string
+	limit int64 // Bytes the store may hold, uploads in progress included
+	ttl   time.Duration
+	used  int64
+	files map[string]*storedFile
+}
+
+// NewFileStore starts an empty store in dir. Files do not outlive the
+// server, so anything left there by an earlier run is removed.
+func NewFileStore(dir string, limit ByteSize, ttl time.Duration) (*FileStore, error) {
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileStore{
+		dir:   dir,
+		limit: int64(limit),
+		ttl:   ttl,
+		files: make(map[string]*storedFile),
+	}, nil
+}
+
+// Begin reserves space for an upload and returns the ID its chunks carry
+func (s *FileStore) Begin(file models.File, room, clientID string, owner *Client) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.used+file.Size > s.limit {
+		return "", ErrFileStoreFull
+	}
+	file.ID = rand.Text()[:models.FileIDSize]
+	out, err := os.OpenFile(s.path(file.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return "", err
+	}
+
+	s.files[file.ID] = &storedFile{
+		File:     file,
+		room:     room,
+		clientID: clientID,
+		expires:  time.Now().Add(s.ttl),
+		owner:    owner,
+		out:      out,
+		hash:     sha256.New(),
+	}
+	s.used += file.Size
+	return file.ID, nil
+}
+
+// Write appends a chunk to one of owner's uploads. It returns the file once
+// the last chunk is in and the checksum matches, nil while more is due, and
+// the file with an error when the upload failed and was thrown away.
+// ErrFileNotFound means the chunk belongs to no upload of owner's.
+func (s *FileStore) Write(owner *Client, id string, chunk []byte) (*storedFile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file := s.files[id]
+	if file == nil || file.owner != owner {
+		return nil, ErrFileNotFound
+	}
+	if file.received+int64(len(chunk)) > file.Size {
+		s.remove(file)
+		return file, ErrFileTooLong
+	}
+	if _, err := file.out.Write(chunk); err != nil {
+		s.remove(file)
+		return file, err
+	}
+	file.hash.Write(chunk)
+	file.received += int64(len(chunk))
+	if file.received < file.Size {
+		return nil, nil
+	}
+
+	err := file.out.Close()
+	file.out, file.owner = nil, nil
+	if err == nil && hex.EncodeToString(file.hash.Sum(nil)) != file.SHA256 {
+		err = ErrChecksum
+	}
+	if err != nil {
+		s.remove(file)
+		return file, err
+	}
+	file.expires = time.Now().Add(s.ttl)
+	return file, nil
+}
+
+// Abort throws away the unfinished uploads of a connection that went away
+func (s *FileStore) Abort(owner *Client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, file := range s.files {
+		if file.owner == owner {
+			s.remove(file)
+		}
+	}
+}
+
+// Find returns a complete file, or nil
+func (s *FileStore) Find(id string) *storedFile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file := s.files[id]
+	if file == nil || file.owner != nil {
+		return nil
+	}
+	return file
+}
+
+// Open opens a complete file for reading
+func (s *FileStore) Open(id string) (*os.File, *storedFile, error) {
+	file := s.Find(id)
+	if file == nil {
+		return nil, nil, ErrFileNotFound
+	}
+	f, err := os.Open(s.path(id))
+	if err != nil {
+		return nil, nil, ErrFileNotFound
+	}
+	return f, file, nil
+}
+
+// Prune removes expired files and uploads that never finished
+func (s *FileStore) Prune(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, file := range s.files {
+		if now.After(file.expires) {
+			s.remove(file)
+		}
+	}
+}
+
+// remove deletes a file and frees its space. Callers hold s.mu.
+func (s *FileStore) remove(file *storedFile) {
+	if file.out != nil {
+		file.out.Close()
+		file.out = nil
+	}
+	os.Remove(s.path(file.ID))
+	delete(s.files, file.ID)
+	s.used -= file.Size
+}
+
+// path is where a file's content lives
+func (s *FileStore) path(id string) string {
+	return filepath.Join(s.dir, id)
+}
+
+// cleanFileName keeps the last element of an offered file name, dropping
+// anything a terminal or another user's disk should not see, or returns ""
+func cleanFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	if name == "." || name == "/" || name == ".." || len([]rune(name)) > 100 {
+		return ""
+	}
+	return strings.TrimSpace(name)
+}
+
+// validDigest reports whether s is a hex SHA-256 digest
+func validDigest(s string) bool {
+	decoded, err := hex.DecodeString(s)
+	return err == nil && len(decoded) == sha256.Size
+}
@@ -0,0 +1,215 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"terminal-chat/models"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// waitTime bounds how long a test waits for a frame it expects
+const waitTime = 2 * time.Second
+
+// testServer is a chat server on a local port, with everything it keeps
+// on disk in a temporary directory
+type testServer struct {
+	*httptest.Server
+	hub      *Hub
+	accounts *AccountStore
+	dir      string
+}
+
+// TestMain hashes passwords at the lowest cost, since tests register
+// accounts by the dozen
+func TestMain(m *testing.M) {
+	passwordCost = bcrypt.MinCost
+	os.Exit(m.Run())
+}
+
+// newTestHub creates a hub with an in-memory history and everything else
+// in dir, without running it
+func newTestHub(t *testing.T, cfg *Config, dir string) *Hub {
+	t.Helper()
+	accounts, err := NewAccountStore(dir+"/accounts.json", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readMarks, err := NewReadMarks("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	roomStates, err := NewRoomStates(dir + "/rooms.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := NewFileStore(dir+"/files", cfg.FileStoreSize, cfg.FileTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewHub(cfg, NewMemoryStore(), accounts, readMarks, roomStates, files)
+}
+
+// newTestServer starts a server with an in-memory history and stops it
+// when the test ends
+func newTestServer(t *testing.T, cfg *Config) *testServer {
+	t.Helper()
+	dir := t.TempDir()
+	hub := newTestHub(t, cfg, dir)
+	accounts := hub.accounts
+	go hub.Run()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) { ServeWS(hub, accounts, w, r) })
+	mux.HandleFunc("/register", handleRegister(accounts))
+	mux.HandleFunc("/login", handleLogin(accounts))
+	mux.HandleFunc("/rooms", handleRooms(hub))
+	mux.HandleFunc("/metrics", handleMetrics(hub))
+	srv := &testServer{Server: httptest.NewServer(mux), hub: hub, accounts: accounts, dir: dir}
+
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), waitTime)
+		defer cancel()
+		hub.Shutdown(ctx, "test over")
+		srv.Close()
+	})
+	return srv
+}
+
+// register creates an account and returns a session token for it
+func (s *testServer) register(t *testing.T, username string) string {
+	t.Helper()
+	if err := s.accounts.Register(username, "password1"); err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := s.accounts.Login(username, "password1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// testConn is a websocket connection to a testServer whose frames are
+// read in the background
+type testConn struct {
+	t      *testing.T
+	conn   *websocket.Conn
+	frames chan models.Message
+	binary chan []byte
+	closed chan error
+}
+
+// dial connects to the server with the query string of the URL, such as
+// "username=alice&room=general"
+func (s *testServer) dial(t *testing.T, query string) *testConn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(s.URL, "http") + "/ws?" + query
+	conn, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		t.Fatalf("dial %s: %v (status %d)", query, err, status)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	c := &testConn{
+		t:      t,
+		conn:   conn,
+		frames: make(chan models.Message, 1000),
+		binary: make(chan []byte, 1000),
+		closed: make(chan error, 1),
+	}
+	go func() {
+		for {
+			kind, data, err := conn.ReadMessage()
+			if err != nil {
+				c.closed <- err
+				return
+			}
+			if kind == websocket.BinaryMessage {
+				c.binary <- data
+				continue
+			}
+			var msg models.Message
+			if err := json.Unmarshal(data, &msg); err == nil {
+				c.frames <- msg
+			}
+		}
+	}()
+	return c
+}
+
+// send writes a message as a text frame
+func (c *testConn) send(msg *models.Message) {
+	c.t.Helper()
+	if err := c.conn.WriteJSON(msg); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// next returns the next frame of type typ, skipping others, and fails the
+// test if none arrives in time
+func (c *testConn) next(typ models.MessageType) models.Message {
+	c.t.Helper()
+	timeout := time.After(waitTime)
+	for {
+		select {
+		case msg := <-c.frames:
+			if msg.Type == typ {
+				return msg
+			}
+		case err := <-c.closed:
+			c.t.Fatalf("connection closed waiting for %s: %v", typ, err)
+		case <-timeout:
+			c.t.Fatalf("no %s frame arrived", typ)
+		}
+	}
+}
+
+// nextWhere returns the next frame of type typ that match accepts
+func (c *testConn) nextWhere(typ models.MessageType, match func(models.Message) bool) models.Message {
+	c.t.Helper()
+	for {
+		if msg := c.next(typ); match(msg) {
+			return msg
+		}
+	}
+}
+
+// quiet fails the test if a frame of type typ arrives within d
+func (c *testConn) quiet(typ models.MessageType, d time.Duration) {
+	c.t.Helper()
+	timeout := time.After(d)
+	for {
+		select {
+		case msg := <-c.frames:
+			if msg.Type == typ {
+				c.t.Fatalf("unexpected %s frame: %q", typ, msg.Content)
+			}
+		case <-timeout:
+			return
+		}
+	}
+}
+
+// joined waits until the connection is in room, after its history
+func (c *testConn) joined(room string) {
+	c.t.Helper()
+	c.nextWhere(models.MessageTypeUserList, func(msg models.Message) bool { return msg.Room == room })
+}
+
+// chat sends a chat message to room
+func (c *testConn) chat(room, content string) {
+	c.t.Helper()
+	c.send(models.NewMessage(models.MessageTypeChat, "", content, room))
+}
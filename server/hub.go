@@ -1,37 +1,126 @@
 package server
 
 import (
+	"context"
+	"crypto/rand"
+	"fmt"
 	"log"
+	"sort"
 	"strings"
+	"sync"
 	"terminal-chat/models"
 	"terminal-chat/utils"
 	"time"
+	"unicode"
+
+	"github.com/gorilla/websocket"
 )
 
+// clientMessageTypes lists the message types a client may originate.
+// Everything else (join, leave, userlist, system, error) is server-only.
+var clientMessageTypes = map[models.MessageType]bool{
+	models.MessageTypeChat:     true,
+	models.MessageTypeGIF:      true,
+	models.MessageTypeJoinRoom: true,
+	models.MessageTypePartRoom: true,
+	models.MessageTypeDirect:   true,
+	models.MessageTypeRoomList: true,
+	models.MessageTypeBackfill: true,
+	models.MessageTypeEdit:     true,
+	models.MessageTypeDelete:   true,
+	models.MessageTypeReaction: true,
+
+	models.MessageTypeTypingStart: true,
+	models.MessageTypeTypingStop:  true,
+	models.MessageTypeRead:        true,
+	models.MessageTypeReceipts:    true,
+	models.MessageTypeModerate:    true,
+	models.MessageTypeTopic:       true,
+	models.MessageTypeAccess:      true,
+
+	models.MessageTypeFileOffer:   true,
+	models.MessageTypeFileRequest: true,
+}
+
+// maxRoomNameLength bounds room names accepted by joinRoom
+const maxRoomNameLength = 32
+
+// maxBackfill caps the messages sent for one backfill or rejoin so they
+// fit in a connection's send queue
+const maxBackfill = 200
+
+// envelope pairs an inbound frame with the connection it arrived on
+type envelope struct {
+	client  *Client
+	data    []byte
+	verdict verdict // Set when the rate limiter dropped the frame
+
+	// A finished upload instead of data, and why it failed if it did
+	upload    *storedFile
+	uploadErr error
+}
+
 // Hub maintains the set of active clients and broadcasts messages
 type Hub struct {
 	clients    map[*Client]bool
 	rooms      map[string]map[*Client]bool
-	broadcast  chan []byte
+	broadcast  chan *envelope
 	register   chan *Client
 	unregister chan *Client
+	roomList   chan chan []models.Room
+	metricsReq chan chan *metricsSnapshot
+	shutdown   chan string
+	done       chan struct{}  // Closed once the hub has stopped
+	writers    sync.WaitGroup // Running writePumps, waited on at shutdown
 	userColors map[string]string
+	seqs       map[string]uint64               // Last sequence number given out per room
+	typing     map[string]map[string]time.Time // Expiry of each typing user per room
+	limiter    *limiter                        // Rate limits, applied by readPumps
+	metrics    metrics                         // Counts for /metrics
+
+	cfg        *Config
+	store      MessageStore
+	accounts   *AccountStore
+	readMarks  *ReadMarks
+	roomStates *RoomStates
+	files      *FileStore
 }
 
-// NewHub creates a new Hub
-func NewHub() *Hub {
+// NewHub creates a new Hub that records room traffic in store, how far
+// users have read it in readMarks, room roles and bans in roomStates, and
+// shared files in files. Names held by accounts are kept free for their owners.
+func NewHub(cfg *Config, store MessageStore, accounts *AccountStore, readMarks *ReadMarks, roomStates *RoomStates, files *FileStore) *Hub {
 	return &Hub{
 		clients:    make(map[*Client]bool),
 		rooms:      make(map[string]map[*Client]bool),
-		broadcast:  make(chan []byte),
+		broadcast:  make(chan *envelope),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
+		roomList:   make(chan chan []models.Room),
+		metricsReq: make(chan chan *metricsSnapshot),
+		shutdown:   make(chan string),
+		done:       make(chan struct{}),
 		userColors: make(map[string]string),
+		seqs:       make(map[string]uint64),
+		typing:     make(map[string]map[string]time.Time),
+		limiter:    newLimiter(cfg),
+		metrics:    newMetrics(),
+
+		cfg:        cfg,
+		store:      store,
+		accounts:   accounts,
+		readMarks:  readMarks,
+		roomStates: roomStates,
+		files:      files,
 	}
 }
 
 // Run starts the hub
 func (h *Hub) Run() {
+	// Housekeeping: expire typing signals and save read marks
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
 	for {
 		select {
 		case client := <-h.register:
@@ -40,23 +129,53 @@ func (h *Hub) Run() {
 		case client := <-h.unregister:
 			h.unregisterClient(client)
 
-		case message := <-h.broadcast:
-			h.broadcastMessage(message)
+		case env := <-h.broadcast:
+			h.handleMessage(env)
+
+		case reply := <-h.metricsReq:
+			reply <- h.snapshotMetrics()
+
+		case reply := <-h.roomList:
+			reply <- h.listRooms(nil)
+
+		case now := <-ticker.C:
+			h.expireTyping(now)
+			h.saveReadMarks()
+			h.files.Prune(now)
+
+		case reason := <-h.shutdown:
+			h.closeAll(reason)
+			close(h.done)
+			return
 		}
 	}
 }
 
 func (h *Hub) registerClient(client *Client) {
-	// Clean the room name to avoid encoding issues
-	client.Room = strings.TrimSpace(client.Room)
+	client.JoinedAt = time.Now()
+	client.rooms = make(map[string]bool)
 
+	// Names are settled here, where every connection is known
+	asked := client.Username
+	if client.registered {
+		h.evictGuests(client)
+	}
+	client.Username = h.uniqueName(client)
+	close(client.named)
 	h.clients[client] = true
 
-	// Add to room
-	if h.rooms[client.Room] == nil {
-		h.rooms[client.Room] = make(map[*Client]bool)
+	welcome := models.NewMessage(models.MessageTypeWelcome, client.Username, "", "")
+	welcome.MOTD = h.cfg.MOTD
+	welcome.Limits = &models.Limits{
+		Messages: h.cfg.MessageLimit.model(),
+		GIFs:     h.cfg.GIFLimit.model(),
+		Joins:    h.cfg.JoinLimit.model(),
+	}
+	if client.Username != asked {
+		welcome.Content = renameNotice(asked, client.Username)
+		log.Printf("🪪 %s is taken, %s connects as %s", asked, client.ip, client.Username)
 	}
-	h.rooms[client.Room][client] = true
+	h.sendToClient(client, welcome)
 
 	// Assign color to user
 	if h.userColors[client.Username] == "" {
@@ -65,19 +184,9 @@ func (h *Hub) registerClient(client *Client) {
 		h.userColors[client.Username] = colors[colorIndex]
 	}
 
-	log.Printf("✓ User %s joined room '%s'", client.Username, client.Room)
+	log.Printf("✓ User %s connected", client.Username)
 
-	// Send join message
-	joinMsg := models.NewMessage(models.MessageTypeJoin, client.Username,
-		"joined the chat", client.Room)
-	joinMsg.Color = h.userColors[client.Username]
-
-	// Send messages separately with a small delay
-	h.broadcastToRoom(joinMsg.ToJSON(), client.Room)
-
-	// Small delay before sending user list
-	time.Sleep(10 * time.Millisecond)
-	h.sendUserList(client.Room)
+	h.joinRoom(client, client.initialRoom, client.initialSince, "")
 }
 
 func (h *Hub) unregisterClient(client *Client) {
@@ -85,89 +194,581 @@ func (h *Hub) unregisterClient(client *Client) {
 		delete(h.clients, client)
 		close(client.send)
 
-		// Remove from room
-		if room, exists := h.rooms[client.Room]; exists {
-			delete(room, client)
-			if len(room) == 0 {
-				delete(h.rooms, client.Room)
+		for room := range client.rooms {
+			h.removeFromRoom(client, room)
+
+			log.Printf("%s User %s left room %s",
+				utils.ColorRed("✗"), client.Username, room)
+
+			// Send leave message, saying why when the connection died
+			content := "left the chat"
+			if client.leaveReason != "" {
+				content += " (" + client.leaveReason + ")"
 			}
+			leaveMsg := models.NewMessage(models.MessageTypeLeave, client.Username,
+				content, room)
+			leaveMsg.Color = h.userColors[client.Username]
+			h.broadcastToRoom(leaveMsg, room)
+
+			// Send updated user list
+			h.sendUserList(room)
 		}
+	}
+}
+
+// joinRoom adds a connection to a room, replays its history and announces it.
+// A client rejoining after a reconnect passes the last seq it saw in since
+// and gets everything newer instead of the usual history.
+func (h *Hub) joinRoom(client *Client, room string, since uint64, key string) {
+	// Clean the room name to avoid encoding issues
+	room = strings.TrimSpace(room)
+	if err := validateRoomName(room); err != nil {
+		h.sendError(client, models.ErrCodeBadRoom, err.Error(), room)
+		return
+	}
+	if client.rooms[room] {
+		h.sendError(client, models.ErrCodeAlreadyJoined,
+			fmt.Sprintf("You are already in room '%s'", room), room)
+		return
+	}
+	// Checked here so that bans also hold for the room a connection
+	// asks for in registerClient
+	if ban := h.roomStates.Find(room).Banned(client.Username, client.ip); ban != nil {
+		log.Printf("🔨 Refused %s (%s) entry to banned room '%s'", client.Username, client.ip, room)
+		h.sendError(client, models.ErrCodeBanned,
+			fmt.Sprintf("You are banned from room '%s'", room), room)
+		return
+	}
+	if !h.admit(client, room, key) {
+		return
+	}
+
+	// Add to room
+	if h.rooms[room] == nil {
+		h.rooms[room] = make(map[*Client]bool)
+		h.claimRoom(client, room)
+	}
+	h.rooms[room][client] = true
+	client.rooms[room] = true
+
+	log.Printf("✓ User %s joined room '%s'", client.Username, room)
+
+	// Catch the newcomer up before announcing them
+	h.sendReadMark(client, room)
+	h.sendRoomInfo(client, room)
+	h.replayHistory(client, room, since)
 
-		log.Printf("%s User %s left room %s",
-			utils.ColorRed("✗"), client.Username, client.Room)
+	// Send join message; its seq tells clients where the room stands
+	joinMsg := models.NewMessage(models.MessageTypeJoin, client.Username,
+		"joined the chat", room)
+	joinMsg.Color = h.userColors[client.Username]
+	joinMsg.Seq = h.headSeq(room)
+
+	// Queues are ordered, so members see the join before the new user list
+	h.broadcastToRoom(joinMsg, room)
+	h.sendUserList(room)
+}
+
+// partRoom takes a connection out of one room while leaving it connected
+func (h *Hub) partRoom(client *Client, room string) {
+	room = strings.TrimSpace(room)
+	if !client.rooms[room] {
+		h.sendError(client, models.ErrCodeWrongRoom,
+			fmt.Sprintf("You are not a member of room '%s'", room), room)
+		return
+	}
+
+	h.removeFromRoom(client, room)
+
+	log.Printf("%s User %s left room %s",
+		utils.ColorRed("✗"), client.Username, room)
 
-		// Send leave message
-		leaveMsg := models.NewMessage(models.MessageTypeLeave, client.Username,
-			"left the chat", client.Room)
-		leaveMsg.Color = h.userColors[client.Username]
-		h.broadcastToRoom(leaveMsg.ToJSON(), client.Room)
+	leaveMsg := models.NewMessage(models.MessageTypeLeave, client.Username,
+		"left the chat", room)
+	leaveMsg.Color = h.userColors[client.Username]
 
-		// Send updated user list
-		h.sendUserList(client.Room)
+	// The leaver is no longer in the room, so confirm to it directly
+	h.sendToClient(client, leaveMsg)
+	h.broadcastToRoom(leaveMsg, room)
+	h.sendUserList(room)
+}
+
+// removeFromRoom drops room membership on both sides and forgets empty rooms
+func (h *Hub) removeFromRoom(client *Client, room string) {
+	delete(client.rooms, room)
+	if members, exists := h.rooms[room]; exists {
+		delete(members, client)
+		if len(members) == 0 {
+			delete(h.rooms, room)
+		}
 	}
+	h.stopTyping(client.Username, room)
 }
 
-func (h *Hub) broadcastMessage(message []byte) {
-	msg, err := models.MessageFromJSON(message)
+// handleMessage validates an inbound frame and dispatches it by type
+func (h *Hub) handleMessage(env *envelope) {
+	client := env.client
+	if env.upload != nil {
+		h.finishUpload(client, env.upload, env.uploadErr)
+		return
+	}
+	if env.verdict != allowed {
+		h.handleLimited(env)
+		return
+	}
+
+	msg, err := models.MessageFromJSON(env.data)
 	if err != nil {
-		log.Printf("Error parsing message: %v", err)
+		log.Printf("Error parsing message from %s: %v", client.Username, err)
+		h.sendError(client, models.ErrCodeBadMessage, "Malformed message", "")
 		return
 	}
 
-	// Add color to message if not set
-	if msg.Color == "" {
-		msg.Color = h.userColors[msg.Username]
+	h.countReceived(msg.Type)
+	if !clientMessageTypes[msg.Type] {
+		log.Printf("🚫 %s tried to send forbidden message type %q", client.Username, msg.Type)
+		h.rejectMessage(client, msg, models.ErrCodeForbiddenType,
+			fmt.Sprintf("Message type %q cannot be sent by clients", msg.Type))
+		return
 	}
+
+	clearServerFields(msg)
+
+	switch msg.Type {
+	case models.MessageTypeJoinRoom:
+		h.joinRoom(client, msg.Room, msg.Since, msg.Key)
+	case models.MessageTypePartRoom:
+		h.partRoom(client, msg.Room)
+	case models.MessageTypeDirect:
+		h.sendDirectMessage(client, msg)
+	case models.MessageTypeBackfill:
+		h.backfill(client, msg)
+	case models.MessageTypeEdit, models.MessageTypeDelete:
+		h.changeMessage(client, msg)
+	case models.MessageTypeReaction:
+		h.react(client, msg)
+	case models.MessageTypeTypingStart, models.MessageTypeTypingStop:
+		h.setTyping(client, msg)
+	case models.MessageTypeRead:
+		h.markRead(client, msg)
+	case models.MessageTypeReceipts:
+		h.sendReceipts(client, msg)
+	case models.MessageTypeModerate:
+		h.moderate(client, msg)
+	case models.MessageTypeTopic:
+		h.setTopic(client, msg)
+	case models.MessageTypeAccess:
+		h.setAccess(client, msg)
+	case models.MessageTypeFileOffer:
+		h.offerFile(client, msg)
+	case models.MessageTypeFileRequest:
+		h.requestFile(client, msg)
+	case models.MessageTypeRoomList:
+		reply := models.NewMessage(models.MessageTypeRoomList, "system", "", "")
+		reply.Rooms = h.listRooms(client)
+		h.sendToClient(client, reply)
+	default:
+		h.broadcastMessage(client, msg)
+	}
+}
+
+func (h *Hub) broadcastMessage(client *Client, msg *models.Message) {
+	room, ok := h.resolveRoom(client, msg.Room)
+	if !ok && room == "" {
+		h.rejectMessage(client, msg, models.ErrCodeWrongRoom,
+			"Messages must name a room when you are in several rooms")
+		return
+	}
+	if !ok {
+		log.Printf("🚫 %s tried to post into room '%s' without joining it", client.Username, msg.Room)
+		h.rejectMessage(client, msg, models.ErrCodeWrongRoom,
+			fmt.Sprintf("You are not a member of room '%s'", msg.Room))
+		return
+	}
+	if !h.checkPosting(client, msg, room) {
+		return
+	}
+	if msg.ReplyTo != "" && !h.attachParent(client, msg, room) {
+		return
+	}
+
+	// Stamp the sender identity from the connection, never from the payload
+	msg.ID = rand.Text()
+	msg.Username = client.Username
+	msg.Author = client.author()
+	msg.Room = room
+	msg.Color = h.userColors[client.Username]
 	msg.Timestamp = time.Now()
+	msg.Seq = h.nextSeq(room)
+
+	if err := h.store.Append(msg); err != nil {
+		log.Printf("⚠️ Failed to store message in room '%s': %v", msg.Room, err)
+	}
 
-	// Debug logging
-	log.Printf("Broadcasting message - Type: %s, User: %s, Content: %s, Room: %s",
+	h.debugf("Broadcasting message - Type: %s, User: %s, Content: %s, Room: %s",
 		msg.Type, msg.Username, msg.Content, msg.Room)
 
 	// Broadcast to all clients in the room
-	h.broadcastToRoom(msg.ToJSON(), msg.Room)
+	h.broadcastToRoom(msg, msg.Room)
+	h.acknowledge(client, msg)
+}
+
+// sendDirectMessage routes a private message to every connection of the
+// recipient, whatever rooms they are in, and echoes it back to the sender
+func (h *Hub) sendDirectMessage(client *Client, msg *models.Message) {
+	recipient := strings.TrimSpace(msg.Recipient)
+	if recipient == "" {
+		h.rejectMessage(client, msg, models.ErrCodeBadMessage, "Direct messages need a recipient")
+		return
+	}
+
+	var targets []*Client
+	canonical := canonicalName(recipient)
+	for other := range h.clients {
+		if canonicalName(other.Username) == canonical {
+			targets = append(targets, other)
+		}
+	}
+	if len(targets) == 0 {
+		h.rejectMessage(client, msg, models.ErrCodeUserOffline,
+			fmt.Sprintf("%s is not online", recipient))
+		return
+	}
+	recipient = targets[0].Username // Spelled the way its owner spells it
+
+	// Stamp the sender identity from the connection, never from the payload
+	msg.ID = rand.Text()
+	msg.Username = client.Username
+	msg.Recipient = recipient
+	msg.Room = ""
+	msg.ReplyTo = ""
+	msg.Color = h.userColors[client.Username]
+	msg.Timestamp = time.Now()
+
+	log.Printf("✉️ Direct message from %s to %s", msg.Username, recipient)
+
+	for _, target := range targets {
+		h.sendToClient(target, msg)
+	}
+	if recipient != client.Username {
+		h.sendToClient(client, msg)
+	}
+	h.acknowledge(client, msg)
+}
+
+// clearServerFields drops the fields only the server may set, so clients
+// cannot forge authorship, edits, reactions, history, quotes or receipts
+func clearServerFields(msg *models.Message) {
+	msg.History = false
+	msg.Edited = false
+	msg.Deleted = false
+	msg.Reactions = nil
+	msg.Thread = ""
+	msg.Quote = nil
+	msg.ReadBy = nil
+	msg.Unread = nil
+	msg.RoomInfo = nil
+	msg.Author = ""
+	if msg.Type != models.MessageTypeFileOffer {
+		msg.File = nil // Only the server attaches shared files
+	}
+}
+
+// resolveRoom picks the room a client message targets. An empty room is
+// accepted only when the connection sits in exactly one room.
+func (h *Hub) resolveRoom(client *Client, room string) (string, bool) {
+	room = strings.TrimSpace(room)
+	if room == "" && len(client.rooms) == 1 {
+		for only := range client.rooms {
+			return only, true
+		}
+	}
+	return room, client.rooms[room]
+}
+
+// validateRoomName rejects names that cannot be shown or typed sensibly
+func validateRoomName(room string) error {
+	if room == "" {
+		return fmt.Errorf("room name cannot be empty")
+	}
+	if len(room) > maxRoomNameLength {
+		return fmt.Errorf("room name too long (max %d characters)", maxRoomNameLength)
+	}
+	if strings.ContainsFunc(room, unicode.IsSpace) {
+		return fmt.Errorf("room name cannot contain spaces")
+	}
+	return nil
+}
+
+// replayHistory sends the latest stored messages of a room to the client,
+// or those after since when it is catching up
+func (h *Hub) replayHistory(client *Client, room string, since uint64) {
+	var messages []*models.Message
+	var err error
+	switch {
+	case since > 0:
+		messages, err = h.store.Range(room, since, 0, maxBackfill)
+	case h.cfg.HistoryLimit > 0:
+		messages, err = h.store.Recent(room, h.cfg.HistoryLimit)
+	}
+	if err != nil {
+		log.Printf("⚠️ Failed to load history for room '%s': %v", room, err)
+		return
+	}
+
+	for _, msg := range messages {
+		msg.History = true
+		h.sendToClient(client, msg)
+	}
+}
+
+// backfill resends a range of a room's messages that a client missed
+func (h *Hub) backfill(client *Client, msg *models.Message) {
+	room, ok := h.resolveRoom(client, msg.Room)
+	if !ok {
+		h.rejectMessage(client, msg, models.ErrCodeWrongRoom,
+			fmt.Sprintf("You are not a member of room '%s'", msg.Room))
+		return
+	}
+
+	messages, err := h.store.Range(room, msg.Since, msg.Until, maxBackfill)
+	if err != nil {
+		log.Printf("⚠️ Failed to backfill room '%s': %v", room, err)
+		return
+	}
+
+	log.Printf("🔁 Backfilling %d messages of room '%s' for %s", len(messages), room, client.Username)
+	for _, missed := range messages {
+		missed.History = true
+		h.sendToClient(client, missed)
+	}
+}
+
+// headSeq returns the last sequence number given out in a room, picking
+// up from the store the first time the room is seen
+func (h *Hub) headSeq(room string) uint64 {
+	if seq, ok := h.seqs[room]; ok {
+		return seq
+	}
+	seq, err := h.store.LastSeq(room)
+	if err != nil {
+		log.Printf("⚠️ Failed to read the last seq of room '%s': %v", room, err)
+	}
+	h.seqs[room] = seq
+	return seq
+}
+
+// nextSeq numbers the next message in a room
+func (h *Hub) nextSeq(room string) uint64 {
+	seq := h.headSeq(room) + 1
+	h.seqs[room] = seq
+	return seq
+}
+
+// sendToClient queues a message for a single client without blocking the
+// hub. Clients already dropped are skipped: their queue is closed, though
+// their readPump may still be delivering frames the hub answers.
+func (h *Hub) sendToClient(client *Client, msg *models.Message) {
+	if !h.clients[client] {
+		return
+	}
+	select {
+	case client.send <- wire(msg):
+		h.countSent(msg.Type, 1)
+	default:
+		log.Printf("❌ Failed to send to client: %s (queue full)", client.Username)
+		h.metrics.dropped["direct"]++
+	}
+}
+
+// wire encodes a message for clients, leaving out who it is stored as
+// written by
+func wire(msg *models.Message) []byte {
+	if msg.Author != "" {
+		out := *msg
+		out.Author = ""
+		msg = &out
+	}
+	return msg.ToJSON()
+}
+
+// sendError reports a rejected frame back to the offending connection
+func (h *Hub) sendError(client *Client, code, content, room string) {
+	h.sendToClient(client, models.NewErrorMessage(code, content, room))
+}
+
+// rejectMessage reports a refused message back to its sender, tagged with
+// the sender's correlation ID so the client can mark it as failed
+func (h *Hub) rejectMessage(client *Client, msg *models.Message, code, content string) {
+	errMsg := models.NewErrorMessage(code, content, msg.Room)
+	errMsg.ClientID = msg.ClientID
+	h.sendToClient(client, errMsg)
+}
+
+// acknowledge tells the sender its message has been handed to every
+// recipient, unless the sender itself was dropped while fanning out
+func (h *Hub) acknowledge(client *Client, msg *models.Message) {
+	if !h.clients[client] {
+		return
+	}
+	h.sendToClient(client, models.NewAckMessage(msg))
 }
 
-func (h *Hub) broadcastToRoom(message []byte, room string) {
+// debugf logs per-message detail, which only -debug turns on since it
+// includes what users write
+func (h *Hub) debugf(format string, args ...any) {
+	if h.cfg.Debug {
+		log.Printf(format, args...)
+	}
+}
+
+// broadcastToRoom queues a message to every member of a room, dropping
+// members whose queues are full
+func (h *Hub) broadcastToRoom(msg *models.Message, room string) {
 	if roomClients, exists := h.rooms[room]; exists {
-		log.Printf("📡 Broadcasting to %d clients in room '%s'", len(roomClients), room)
+		message := wire(msg)
+		h.debugf("📡 Broadcasting to %d clients in room '%s'", len(roomClients), room)
 
+		start := time.Now()
 		successCount := 0
 		for client := range roomClients {
 			select {
 			case client.send <- message:
 				successCount++
-				log.Printf("✅ Message sent to client: %s", client.Username)
+				h.debugf("✅ Message sent to client: %s", client.Username)
 			default:
 				// Client's send channel is full or closed
 				log.Printf("❌ Failed to send to client: %s (removing)", client.Username)
-				close(client.send)
-				delete(h.clients, client)
-				delete(roomClients, client)
+				h.metrics.dropped["broadcast"]++
+				h.dropClient(client)
 			}
 		}
-		log.Printf("📊 Successfully sent to %d/%d clients", successCount, len(roomClients))
+		h.metrics.fanout.observe(time.Since(start).Seconds())
+		h.countSent(msg.Type, successCount)
+		h.debugf("📊 Successfully sent to %d/%d clients", successCount, len(roomClients))
 	} else {
 		log.Printf("⚠️ Room '%s' not found for broadcasting", room)
 	}
 }
 
+// Shutdown tells every room the server is going away, closes all
+// connections with a going-away frame once their send queues are flushed,
+// and stops the hub. It returns when every connection is closed or ctx expires.
+func (h *Hub) Shutdown(ctx context.Context, reason string) error {
+	select {
+	case h.shutdown <- reason:
+	case <-h.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	flushed := make(chan struct{})
+	go func() {
+		h.writers.Wait()
+		close(flushed)
+	}()
+
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// closeAll announces shutdown in every room and closes every send queue
+func (h *Hub) closeAll(reason string) {
+	log.Printf("🛑 Shutting down: notifying %d rooms, closing %d connections",
+		len(h.rooms), len(h.clients))
+
+	for room := range h.rooms {
+		notice := models.NewMessage(models.MessageTypeSystem, "system",
+			"🛑 Server is shutting down - you will be disconnected", room)
+		h.broadcastToRoom(notice, room)
+	}
+
+	for client := range h.clients {
+		// writePump drains what is queued, then sends this close frame
+		client.closeCode = websocket.CloseGoingAway
+		client.closeReason = reason
+		delete(h.clients, client)
+		close(client.send)
+	}
+	h.rooms = make(map[string]map[*Client]bool)
+	h.saveReadMarks()
+}
+
+// Rooms returns the live room directory, or nil once the hub has stopped.
+// It is safe to call from any goroutine.
+func (h *Hub) Rooms() []models.Room {
+	reply := make(chan []models.Room, 1)
+	select {
+	case h.roomList <- reply:
+		return <-reply
+	case <-h.done:
+		return nil
+	}
+}
+
+// listRooms builds the room directory, busiest rooms first. Private and
+// protected rooms are left out unless the viewer is in them.
+func (h *Hub) listRooms(viewer *Client) []models.Room {
+	rooms := make([]models.Room, 0, len(h.rooms))
+	for name := range h.rooms {
+		if h.roomStates.Find(name).Hidden() && (viewer == nil || !viewer.rooms[name]) {
+			continue
+		}
+		rooms = append(rooms, h.roomInfo(name))
+	}
+
+	sort.Slice(rooms, func(i, j int) bool {
+		if rooms[i].Members != rooms[j].Members {
+			return rooms[i].Members > rooms[j].Members
+		}
+		return rooms[i].Name < rooms[j].Name
+	})
+	return rooms
+}
+
+// dropClient disconnects a client whose send queue is full. It is removed
+// from every room so no later broadcast writes to its closed channel.
+func (h *Hub) dropClient(client *Client) {
+	if _, ok := h.clients[client]; !ok {
+		return
+	}
+	delete(h.clients, client)
+	close(client.send)
+	for room := range client.rooms {
+		h.removeFromRoom(client, room)
+	}
+}
+
 func (h *Hub) sendUserList(room string) {
 	var users []models.User
 	if roomClients, exists := h.rooms[room]; exists {
 		for client := range roomClients {
 			user := models.User{
 				Username: client.Username,
-				Room:     client.Room,
-				JoinedAt: time.Now(),
+				Room:     room,
+				JoinedAt: client.JoinedAt,
 				Color:    h.userColors[client.Username],
+				Status:   models.UserStatusOnline,
+				Role:     h.role(room, client),
+			}
+			if user.Role == models.RoleMember {
+				user.Role = ""
 			}
 			users = append(users, user)
 		}
 	}
 
+	// Oldest members first so every client sees the same order
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].JoinedAt.Before(users[j].JoinedAt)
+	})
+
 	userListMsg := models.NewMessage(models.MessageTypeUserList, "system", "", room)
-	// You can add users to message content as JSON if needed
+	userListMsg.Users = users
 
-	h.broadcastToRoom(userListMsg.ToJSON(), room)
+	h.broadcastToRoom(userListMsg, room)
 }
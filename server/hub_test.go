@@ -0,0 +1,347 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"terminal-chat/models"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// testClient is a connection the hub knows of but that has no socket, for
+// calling hub methods directly
+func testClient(h *Hub, username string) *Client {
+	return &Client{
+		hub:      h,
+		send:     make(chan []byte, 4),
+		Username: username,
+		rooms:    make(map[string]bool),
+		named:    make(chan struct{}),
+	}
+}
+
+func TestSendToDroppedClient(t *testing.T) {
+	h := newTestHub(t, DefaultConfig(), t.TempDir())
+	client := testClient(h, "alice")
+	h.clients[client] = true
+
+	h.dropClient(client)
+
+	// Its readPump is still running, and the hub answers what it delivers
+	h.sendError(client, models.ErrCodeBadMessage, "Malformed message", "")
+	h.sendNotice(client, "still there?")
+}
+
+func TestFullQueueDropsClient(t *testing.T) {
+	h := newTestHub(t, DefaultConfig(), t.TempDir())
+	slow, other := testClient(h, "slow"), testClient(h, "other")
+	for _, client := range []*Client{slow, other} {
+		h.clients[client] = true
+		client.rooms["r"] = true
+	}
+	h.rooms["r"] = map[*Client]bool{slow: true, other: true}
+
+	for range cap(slow.send) + 1 {
+		h.broadcastToRoom(models.NewMessage(models.MessageTypeSystem, "system", "hi", "r"), "r")
+		<-other.send
+	}
+
+	if h.clients[slow] || h.rooms["r"][slow] {
+		t.Error("the client whose queue filled up is still connected")
+	}
+	if h.metrics.dropped["broadcast"] != 1 {
+		t.Errorf("dropped broadcast sends = %d, want 1", h.metrics.dropped["broadcast"])
+	}
+}
+
+// logBuffer collects the standard logger's output until the test ends
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// captureLog sends the log to a buffer for the rest of the test
+func captureLog(t *testing.T) *logBuffer {
+	logs := &logBuffer{}
+	log.SetOutput(logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return logs
+}
+
+func TestContentIsLoggedOnlyForDebug(t *testing.T) {
+	for _, debug := range []bool{false, true} {
+		logs := captureLog(t)
+		cfg := DefaultConfig()
+		cfg.Debug = debug
+		srv := newTestServer(t, cfg)
+		alice := srv.dial(t, "username=alice&room=r")
+		alice.joined("r")
+
+		msg := models.NewMessage(models.MessageTypeChat, "", "my secret plans", "r")
+		msg.ClientID = "c1"
+		alice.send(msg)
+		alice.next(models.MessageTypeAck)
+
+		if logged := strings.Contains(logs.String(), "my secret plans"); logged != debug {
+			t.Errorf("with debug %v, content logged: %v", debug, logged)
+		}
+	}
+}
+
+func TestRoomsAfterShutdown(t *testing.T) {
+	h := newTestHub(t, DefaultConfig(), t.TempDir())
+	go h.Run()
+	if rooms := h.Rooms(); rooms == nil {
+		t.Fatal("a running hub gave no directory")
+	}
+	if err := h.Shutdown(context.Background(), "test over"); err != nil {
+		t.Fatal(err)
+	}
+
+	listed := make(chan []models.Room)
+	go func() { listed <- h.Rooms() }()
+	select {
+	case rooms := <-listed:
+		if rooms != nil {
+			t.Errorf("a stopped hub listed %v", rooms)
+		}
+	case <-time.After(waitTime):
+		t.Fatal("Rooms blocked after shutdown")
+	}
+}
+
+// members lists the usernames in a userlist message, in order
+func members(list models.Message) []string {
+	names := make([]string, len(list.Users))
+	for i, user := range list.Users {
+		names[i] = user.Username
+	}
+	return names
+}
+
+func TestUserListCarriesMembers(t *testing.T) {
+	srv := newTestServer(t, DefaultConfig())
+	alice := srv.dial(t, "username=alice&room=general")
+	alice.joined("general")
+	bob := srv.dial(t, "username=bob&room=general")
+	bob.joined("general")
+
+	list := alice.nextWhere(models.MessageTypeUserList, func(msg models.Message) bool { return len(msg.Users) == 2 })
+	if got := strings.Join(members(list), ","); got != "alice,bob" {
+		t.Fatalf("members %s, want alice,bob in the order they joined", got)
+	}
+	for _, user := range list.Users {
+		if user.Room != "general" || user.Status != "online" || user.Color == "" || user.JoinedAt.IsZero() {
+			t.Errorf("incomplete entry %+v", user)
+		}
+	}
+
+	bob.conn.Close()
+	list = alice.nextWhere(models.MessageTypeUserList, func(msg models.Message) bool { return len(msg.Users) != 2 })
+	if got := strings.Join(members(list), ","); got != "alice" {
+		t.Fatalf("members %s after bob left, want alice", got)
+	}
+}
+
+func TestJoinAndPartRooms(t *testing.T) {
+	srv := newTestServer(t, DefaultConfig())
+	bob := srv.dial(t, "username=bob&room=random")
+	bob.joined("random")
+	alice := srv.dial(t, "username=alice&room=general")
+	alice.joined("general")
+
+	alice.join("random", "")
+	alice.joined("random")
+	alice.join("random", "")
+	alice.refused(models.ErrCodeAlreadyJoined)
+
+	// Each message reaches only its own room
+	alice.chat("general", "to general")
+	alice.chat("random", "to random")
+	if got := bob.next(models.MessageTypeChat); got.Content != "to random" || got.Room != "random" {
+		t.Fatalf("bob got %q in %s, want only the message to random", got.Content, got.Room)
+	}
+
+	alice.send(models.NewMessage(models.MessageTypePartRoom, "", "", "random"))
+	alice.nextWhere(models.MessageTypeLeave, func(msg models.Message) bool { return msg.Room == "random" })
+	bob.nextWhere(models.MessageTypeLeave, func(msg models.Message) bool { return msg.Username == "alice" })
+
+	alice.chat("random", "after parting")
+	alice.refused(models.ErrCodeWrongRoom)
+	alice.chat("general", "still here")
+	alice.nextWhere(models.MessageTypeChat, func(msg models.Message) bool { return msg.Content == "still here" })
+	bob.quiet(models.MessageTypeChat, 100*time.Millisecond)
+}
+
+// dm sends a direct message to recipient
+func (c *testConn) dm(recipient, content string) {
+	c.t.Helper()
+	msg := models.NewMessage(models.MessageTypeDirect, "", content, "")
+	msg.Recipient = recipient
+	c.send(msg)
+}
+
+func TestDirectMessages(t *testing.T) {
+	srv := newTestServer(t, DefaultConfig())
+	alice := srv.dial(t, "username=alice&room=general")
+	alice.joined("general")
+	bob := srv.dial(t, "username=bob&room=random")
+	bob.joined("random")
+	carol := srv.dial(t, "username=carol&room=general")
+	carol.joined("general")
+
+	alice.dm("BOB", "just between us")
+	got := bob.next(models.MessageTypeDirect)
+	if got.Content != "just between us" || got.Username != "alice" || got.Recipient != "bob" {
+		t.Fatalf("bob got %+v", got)
+	}
+	if echo := alice.next(models.MessageTypeDirect); echo.ID != got.ID {
+		t.Errorf("alice's copy has ID %q, bob's %q", echo.ID, got.ID)
+	}
+	carol.quiet(models.MessageTypeDirect, 100*time.Millisecond)
+
+	alice.dm("dave", "anyone there?")
+	alice.refused(models.ErrCodeUserOffline)
+}
+
+// roomNames lists the rooms of a directory, in order
+func roomNames(rooms []models.Room) string {
+	names := make([]string, len(rooms))
+	for i, room := range rooms {
+		names[i] = room.Name
+	}
+	return strings.Join(names, ",")
+}
+
+// directory asks the server for the room list over the connection
+func (c *testConn) directory() []models.Room {
+	c.t.Helper()
+	c.send(models.NewMessage(models.MessageTypeRoomList, "", "", ""))
+	return c.next(models.MessageTypeRoomList).Rooms
+}
+
+func TestRoomDirectory(t *testing.T) {
+	srv := newTestServer(t, DefaultConfig())
+	owner := srv.dialOwner(t, "owner", "secret")
+	owner.setAccess("secret", models.AccessPrivate, "", "")
+	owner.nextWhere(models.MessageTypeSystem, func(msg models.Message) bool { return msg.Room == "secret" })
+	for _, name := range []string{"alice", "bob"} {
+		srv.dial(t, "username="+name+"&room=general").joined("general")
+	}
+	carol := srv.dial(t, "username=carol&room=lobby")
+	carol.joined("lobby")
+
+	resp, err := http.Get(srv.URL + "/rooms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var listed []models.Room
+	if err := json.NewDecoder(resp.Body).Decode(&listed); err != nil {
+		t.Fatal(err)
+	}
+	if got := roomNames(listed); got != "general,lobby" {
+		t.Fatalf("GET /rooms listed %s, want the busiest public room first", got)
+	}
+	if listed[0].Members != 2 {
+		t.Errorf("general has %d members, want 2", listed[0].Members)
+	}
+
+	if got := roomNames(carol.directory()); got != "general,lobby" {
+		t.Errorf("carol was shown %s", got)
+	}
+	if got := roomNames(owner.directory()); got != "general,lobby,secret" {
+		t.Errorf("the owner of a private room was shown %s", got)
+	}
+}
+
+func TestShutdownNotifiesClients(t *testing.T) {
+	srv := newTestServer(t, DefaultConfig())
+	alice := srv.dial(t, "username=alice&room=general")
+	alice.joined("general")
+
+	ctx, cancel := context.WithTimeout(context.Background(), waitTime)
+	defer cancel()
+	if err := srv.hub.Shutdown(ctx, "maintenance"); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-alice.closed:
+		var closeErr *websocket.CloseError
+		if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseGoingAway || closeErr.Text != "maintenance" {
+			t.Fatalf("connection ended with %v, want going away: maintenance", err)
+		}
+	case <-time.After(waitTime):
+		t.Fatal("the connection stayed open")
+	}
+
+	// The notice was written before the close frame
+	for {
+		select {
+		case msg := <-alice.frames:
+			if msg.Type == models.MessageTypeSystem && strings.Contains(msg.Content, "shutting down") {
+				return
+			}
+		default:
+			t.Fatal("no shutdown notice before the close")
+		}
+	}
+}
+
+func TestSilentPeerIsEvicted(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.PingPeriod = 50 * time.Millisecond
+	cfg.PongWait = 200 * time.Millisecond
+	srv := newTestServer(t, cfg)
+	alice := srv.dial(t, "username=alice&room=general")
+	alice.joined("general")
+
+	// A peer that reads but never answers pings
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws?username=bob&room=general"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetPingHandler(func(string) error { return nil })
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	alice.nextWhere(models.MessageTypeJoin, func(msg models.Message) bool { return msg.Username == "bob" })
+
+	leave := alice.nextWhere(models.MessageTypeLeave, func(msg models.Message) bool { return msg.Username == "bob" })
+	if !strings.Contains(leave.Content, reasonPingTimeout) {
+		t.Errorf("bob left with %q", leave.Content)
+	}
+
+	// A peer that answers pings stays
+	alice.quiet(models.MessageTypeLeave, 2*cfg.PongWait)
+	alice.chat("general", "still here")
+	alice.nextWhere(models.MessageTypeChat, func(msg models.Message) bool { return msg.Content == "still here" })
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"terminal-chat/models"
+)
+
+// sendQueueSize is how many frames the hub may queue for a connection
+// before it is dropped as too slow
+const sendQueueSize = 256
+
+// fanoutBuckets are the upper bounds, in seconds, of the broadcast latency histogram
+var fanoutBuckets = []float64{0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1}
+
+// metrics counts what the hub does, for /metrics. Only the hub goroutine
+// touches it; scrapes get a copy through the hub.
+type metrics struct {
+	received map[models.MessageType]uint64 // Client frames by type, "other" for unknown ones
+	sent     map[models.MessageType]uint64 // Frames queued to connections by type
+	dropped  map[string]uint64             // Sends refused by a full queue, by where
+	fanout   histogram                     // Time taken to queue a broadcast to a room
+}
+
+// newMetrics starts every count at zero. Drops are listed before any
+// happen, so alerts on them have a series to watch.
+func newMetrics() metrics {
+	return metrics{
+		received: make(map[models.MessageType]uint64),
+		sent:     make(map[models.MessageType]uint64),
+		dropped:  map[string]uint64{"broadcast": 0, "direct": 0},
+		fanout:   histogram{counts: make([]uint64, len(fanoutBuckets)+1)},
+	}
+}
+
+// histogram counts observations per bucket of fanoutBuckets, the last
+// count being for values above every bound
+type histogram struct {
+	counts []uint64
+	sum    float64
+}
+
+// observe records one value, in seconds
+func (h *histogram) observe(seconds float64) {
+	i, _ := slices.BinarySearch(fanoutBuckets, seconds)
+	h.counts[i]++
+	h.sum += seconds
+}
+
+// metricsSnapshot is everything /metrics reports, taken in one go by the hub
+type metricsSnapshot struct {
+	clients   int
+	rooms     int
+	queued    int // Frames waiting in every send queue together
+	maxQueued int // Frames waiting in the fullest send queue
+	metrics
+}
+
+// countReceived records a frame from a client, keeping unknown types from
+// growing the label set
+func (h *Hub) countReceived(msgType models.MessageType) {
+	if !clientMessageTypes[msgType] {
+		msgType = "other"
+	}
+	h.metrics.received[msgType]++
+}
+
+// countSent records frames of a type queued to n connections
+func (h *Hub) countSent(msgType models.MessageType, n int) {
+	h.metrics.sent[msgType] += uint64(n)
+}
+
+// snapshotMetrics copies the counters and measures the live state. It runs
+// on the hub goroutine.
+func (h *Hub) snapshotMetrics() *metricsSnapshot {
+	snap := &metricsSnapshot{
+		clients: len(h.clients),
+		rooms:   len(h.rooms),
+		metrics: metrics{
+			received: maps.Clone(h.metrics.received),
+			sent:     maps.Clone(h.metrics.sent),
+			dropped:  maps.Clone(h.metrics.dropped),
+			fanout:   histogram{counts: slices.Clone(h.metrics.fanout.counts), sum: h.metrics.fanout.sum},
+		},
+	}
+	for client := range h.clients {
+		depth := len(client.send)
+		snap.queued += depth
+		snap.maxQueued = max(snap.maxQueued, depth)
+	}
+	return snap
+}
+
+// collectMetrics returns the hub's counters and live state, or nil once
+// the hub has stopped. It is safe to call from any goroutine.
+func (h *Hub) collectMetrics() *metricsSnapshot {
+	reply := make(chan *metricsSnapshot, 1)
+	select {
+	case h.metricsReq <- reply:
+		return <-reply
+	case <-h.done:
+		return nil
+	}
+}
+
+// handleMetrics serves the hub's metrics in the Prometheus text format
+func handleMetrics(hub *Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		snap := hub.collectMetrics()
+		if snap == nil {
+			http.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		snap.write(w)
+	}
+}
+
+// write renders the snapshot in the Prometheus text exposition format
+func (s *metricsSnapshot) write(w io.Writer) error {
+	out := bufio.NewWriter(w)
+
+	gauge := func(name, help string, value int) {
+		fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n", name, help, name, name, value)
+	}
+	gauge("chat_connected_clients", "Connections currently registered with the hub.", s.clients)
+	gauge("chat_rooms", "Rooms with at least one member.", s.rooms)
+	gauge("chat_send_queue_messages", "Frames waiting in every connection's send queue together.", s.queued)
+	gauge("chat_send_queue_max_messages", "Frames waiting in the fullest send queue.", s.maxQueued)
+	gauge("chat_send_queue_capacity", "Frames a send queue holds before its connection is dropped.", sendQueueSize)
+
+	counter(out, "chat_messages_received_total", "Frames received from clients, by message type.", "type", s.received)
+	counter(out, "chat_messages_sent_total", "Frames queued to clients, by message type.", "type", s.sent)
+	counter(out, "chat_send_dropped_total", "Sends refused because a connection's queue was full, by where.", "path", s.dropped)
+
+	name := "chat_broadcast_fanout_seconds"
+	fmt.Fprintf(out, "# HELP %s Time taken to queue a message to every member of a room.\n# TYPE %s histogram\n", name, name)
+	var total uint64
+	for i, n := range s.fanout.counts {
+		total += n
+		le := "+Inf"
+		if i < len(fanoutBuckets) {
+			le = strconv.FormatFloat(fanoutBuckets[i], 'g', -1, 64)
+		}
+		fmt.Fprintf(out, "%s_bucket{le=%q} %d\n", name, le, total)
+	}
+	fmt.Fprintf(out, "%s_sum %s\n%s_count %d\n", name, strconv.FormatFloat(s.fanout.sum, 'g', -1, 64), name, total)
+
+	return out.Flush()
+}
+
+// counter writes a counter family with one series per label value, in order
+func counter[K ~string](out io.Writer, name, help, label string, values map[K]uint64) {
+	fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	keys := make([]K, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		fmt.Fprintf(out, "%s{%s=%q} %d\n", name, label, string(key), values[key])
+	}
+}
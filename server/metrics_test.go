@@ -0,0 +1,92 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"terminal-chat/models"
+	"testing"
+)
+
+func TestMetricsFormat(t *testing.T) {
+	snap := &metricsSnapshot{clients: 3, rooms: 2, queued: 5, maxQueued: 4, metrics: newMetrics()}
+	snap.received[models.MessageTypeChat] = 7
+	snap.received["other"] = 1
+	snap.sent[models.MessageTypeAck] = 7
+	snap.sent[models.MessageTypeChat] = 21
+	snap.dropped["broadcast"] = 2
+	snap.fanout.observe(0.0002)
+	snap.fanout.observe(0.003)
+	snap.fanout.observe(1)
+
+	var out strings.Builder
+	if err := snap.write(&out); err != nil {
+		t.Fatal(err)
+	}
+	text := out.String()
+
+	for _, want := range []string{
+		"# TYPE chat_connected_clients gauge\nchat_connected_clients 3\n",
+		"chat_send_queue_capacity 256\n",
+		"# TYPE chat_messages_received_total counter\n" +
+			"chat_messages_received_total{type=\"chat\"} 7\n" +
+			"chat_messages_received_total{type=\"other\"} 1\n",
+		"chat_messages_sent_total{type=\"ack\"} 7\nchat_messages_sent_total{type=\"chat\"} 21\n",
+		"chat_send_dropped_total{path=\"broadcast\"} 2\nchat_send_dropped_total{path=\"direct\"} 0\n",
+		"# TYPE chat_broadcast_fanout_seconds histogram\n",
+		"chat_broadcast_fanout_seconds_bucket{le=\"0.0001\"} 0\n" +
+			"chat_broadcast_fanout_seconds_bucket{le=\"0.00025\"} 1\n",
+		"chat_broadcast_fanout_seconds_bucket{le=\"0.005\"} 2\n",
+		"chat_broadcast_fanout_seconds_bucket{le=\"0.1\"} 2\n" +
+			"chat_broadcast_fanout_seconds_bucket{le=\"+Inf\"} 3\n" +
+			"chat_broadcast_fanout_seconds_sum 1.0032\n" +
+			"chat_broadcast_fanout_seconds_count 3\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("metrics lack %q in:\n%s", want, text)
+		}
+	}
+}
+
+// sample matches one line of the Prometheus text format that is not a comment
+var sample = regexp.MustCompile(`^[a-z_]+(\{[a-z]+="[^"]*"\})? [0-9.e+-]+$`)
+
+func TestMetricsEndpoint(t *testing.T) {
+	srv := newTestServer(t, DefaultConfig())
+	alice := srv.dial(t, "username=alice&room=r")
+	alice.joined("r")
+	msg := models.NewMessage(models.MessageTypeChat, "", "hello", "r")
+	msg.ClientID = "c1"
+	alice.send(msg)
+	alice.next(models.MessageTypeAck)
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type is %q", ct)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	text := string(body)
+
+	for line := range strings.Lines(text) {
+		line = strings.TrimSuffix(line, "\n")
+		if !strings.HasPrefix(line, "# ") && !sample.MatchString(line) {
+			t.Errorf("malformed line %q", line)
+		}
+	}
+	for _, want := range []string{
+		"chat_connected_clients 1\n",
+		"chat_rooms 1\n",
+		"chat_messages_received_total{type=\"chat\"} 1\n",
+		"chat_messages_sent_total{type=\"ack\"} 1\n",
+		"chat_messages_sent_total{type=\"welcome\"} 1\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("metrics lack %q in:\n%s", want, text)
+		}
+	}
+}
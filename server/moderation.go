@@ -0,0 +1,259 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"terminal-chat/models"
+	"time"
+)
+
+// defaultMuteDuration applies when /mute is given no duration
+const defaultMuteDuration = 10 * time.Minute
+
+// roleRank orders roles so that actors can only act on lower ones
+var roleRank = map[string]int{
+	models.RoleMember:    0,
+	models.RoleModerator: 1,
+	models.RoleOwner:     2,
+}
+
+// moderate applies a moderation action to a room and announces it. The
+// owner may do anything; moderators may kick, ban and mute members but
+// only the owner may op and deop. Nobody may act on their equals.
+func (h *Hub) moderate(client *Client, msg *models.Message) {
+	room, ok := h.resolveRoom(client, msg.Room)
+	if !ok {
+		h.rejectMessage(client, msg, models.ErrCodeWrongRoom,
+			fmt.Sprintf("You are not a member of room '%s'", msg.Room))
+		return
+	}
+	target := h.resolveUser(strings.TrimSpace(msg.User))
+	if target == "" {
+		h.rejectMessage(client, msg, models.ErrCodeBadMessage, fmt.Sprintf("Name a user to %s", msg.Action))
+		return
+	}
+
+	state := h.roomStates.Find(room)
+	actor := h.role(room, client)
+	needed := models.RoleModerator
+	if msg.Action == models.ModOp || msg.Action == models.ModDeop {
+		needed = models.RoleOwner
+	}
+	if roleRank[actor] < roleRank[needed] {
+		log.Printf("🚫 %s tried to %s %s in room '%s' as %s", client.Username, msg.Action, target, room, actor)
+		h.rejectMessage(client, msg, models.ErrCodeNotAllowed,
+			fmt.Sprintf("Only the room's %ss can %s", needed, msg.Action))
+		return
+	}
+	if roleRank[h.nameRole(state, target)] >= roleRank[actor] {
+		h.rejectMessage(client, msg, models.ErrCodeNotAllowed,
+			fmt.Sprintf("You cannot %s %s", msg.Action, target))
+		return
+	}
+
+	reason := strings.TrimSpace(msg.Content)
+	because := ""
+	if reason != "" {
+		because = ": " + reason
+	}
+
+	var notice string
+	switch msg.Action {
+	case models.ModKick:
+		if !h.eject(room, target, fmt.Sprintf("was kicked by %s%s", client.Username, because)) {
+			h.rejectMessage(client, msg, models.ErrCodeNotFound, fmt.Sprintf("%s is not in this room", target))
+			return
+		}
+		if state.Hidden() {
+			h.disallow(state, target) // Coming back takes a new invite
+		}
+		notice = fmt.Sprintf("👢 %s kicked %s from #%s%s", client.Username, target, room, because)
+
+	case models.ModBan:
+		if state.Banned(target, "") != nil {
+			h.rejectMessage(client, msg, models.ErrCodeBadMessage, fmt.Sprintf("%s is already banned", target))
+			return
+		}
+		ban := Ban{Username: target, By: client.Username, Reason: reason, At: time.Now()}
+		if msg.BanIP {
+			ban.IPs = h.addressesOf(target)
+		}
+		state = h.roomStates.Get(room)
+		state.Bans = append(state.Bans, ban)
+		h.eject(room, target, fmt.Sprintf("was banned by %s%s", client.Username, because))
+		notice = fmt.Sprintf("🔨 %s banned %s from #%s%s", client.Username, target, room, because)
+
+	case models.ModUnban:
+		if !state.unban(target) {
+			h.rejectMessage(client, msg, models.ErrCodeNotFound, fmt.Sprintf("%s is not banned", target))
+			return
+		}
+		notice = fmt.Sprintf("🕊️ %s unbanned %s from #%s", client.Username, target, room)
+
+	case models.ModMute:
+		duration := time.Duration(msg.Duration) * time.Second
+		if duration <= 0 {
+			duration = defaultMuteDuration
+		}
+		state = h.roomStates.Get(room)
+		if state.Mutes == nil {
+			state.Mutes = make(map[string]time.Time)
+		}
+		state.Mutes[canonicalName(target)] = time.Now().Add(duration)
+		notice = fmt.Sprintf("🔇 %s muted %s in #%s for %s%s", client.Username, target, room, duration, because)
+
+	case models.ModUnmute:
+		if !state.Muted(target, time.Now()) {
+			h.rejectMessage(client, msg, models.ErrCodeNotFound, fmt.Sprintf("%s is not muted", target))
+			return
+		}
+		delete(state.Mutes, canonicalName(target))
+		notice = fmt.Sprintf("🔊 %s unmuted %s in #%s", client.Username, target, room)
+
+	case models.ModOp:
+		if _, registered := h.accounts.Holder(target); !registered {
+			h.rejectMessage(client, msg, models.ErrCodeNotAllowed,
+				fmt.Sprintf("%s has no account; only registered users can be moderators", target))
+			return
+		}
+		if state.Role(target) == models.RoleModerator {
+			h.rejectMessage(client, msg, models.ErrCodeBadMessage, fmt.Sprintf("%s is already a moderator", target))
+			return
+		}
+		state = h.roomStates.Get(room)
+		state.Moderators = append(state.Moderators, target)
+		notice = fmt.Sprintf("⭐ %s made %s a moderator of #%s", client.Username, target, room)
+
+	case models.ModDeop:
+		if state.Role(target) != models.RoleModerator {
+			h.rejectMessage(client, msg, models.ErrCodeNotFound, fmt.Sprintf("%s is not a moderator", target))
+			return
+		}
+		canonical := canonicalName(target)
+		state.Moderators = slices.DeleteFunc(state.Moderators, func(name string) bool {
+			return canonicalName(name) == canonical
+		})
+		notice = fmt.Sprintf("%s removed %s as a moderator of #%s", client.Username, target, room)
+
+	default:
+		h.rejectMessage(client, msg, models.ErrCodeBadMessage,
+			fmt.Sprintf("Unknown moderation action %q", msg.Action))
+		return
+	}
+
+	log.Printf("🛡️ %s", notice)
+	h.saveRoomStates()
+	announcement := models.NewMessage(models.MessageTypeSystem, "system", notice, room)
+	h.broadcastToRoom(announcement, room)
+	if msg.Action == models.ModOp || msg.Action == models.ModDeop {
+		h.sendUserList(room)
+	}
+}
+
+// claimRoom makes the creator of a room without an owner its owner. Guests
+// cannot own rooms: ownership is saved, and their name is free for anyone
+// once they leave.
+func (h *Hub) claimRoom(client *Client, room string) {
+	if !client.registered {
+		return
+	}
+	if state := h.roomStates.Find(room); state != nil && state.Owner != "" {
+		return
+	}
+	state := h.roomStates.Get(room)
+	state.Owner = client.Username
+	state.CreatedAt = time.Now()
+	log.Printf("👑 %s created room '%s' and owns it", client.Username, room)
+	h.saveRoomStates()
+}
+
+// resolveUser returns the name a user named in a command goes by: their
+// account's, or that of whoever is online under it in any case or form.
+// Anyone else keeps the name as typed.
+func (h *Hub) resolveUser(username string) string {
+	if holder, registered := h.accounts.Holder(username); registered {
+		return holder
+	}
+	canonical := canonicalName(username)
+	for client := range h.clients {
+		if canonicalName(client.Username) == canonical {
+			return client.Username
+		}
+	}
+	return username
+}
+
+// role returns a client's role in a room. Roles belong to accounts, so a
+// guest is a member even if the name once held a role.
+func (h *Hub) role(room string, client *Client) string {
+	if !client.registered {
+		return models.RoleMember
+	}
+	return h.roomStates.Find(room).Role(client.Username)
+}
+
+// nameRole is role for a user named in a command, who may be offline
+func (h *Hub) nameRole(state *RoomState, username string) string {
+	if _, registered := h.accounts.Holder(username); !registered {
+		return models.RoleMember
+	}
+	return state.Role(username)
+}
+
+// checkPosting rejects a message from a user muted in the room, and
+// reports whether it may go ahead
+func (h *Hub) checkPosting(client *Client, msg *models.Message, room string) bool {
+	if !h.roomStates.Find(room).Muted(client.Username, time.Now()) {
+		return true
+	}
+	h.rejectMessage(client, msg, models.ErrCodeMuted, fmt.Sprintf("You are muted in room '%s'", room))
+	return false
+}
+
+// eject takes every connection of a user out of a room, telling them and
+// the room why, and reports whether the user was there
+func (h *Hub) eject(room, username, content string) bool {
+	canonical := canonicalName(username)
+	found := false
+	for member := range h.rooms[room] {
+		if canonicalName(member.Username) != canonical {
+			continue
+		}
+		found = true
+		h.removeFromRoom(member, room)
+
+		leaveMsg := models.NewMessage(models.MessageTypeLeave, username, content, room)
+		leaveMsg.Color = h.userColors[username]
+		h.sendToClient(member, leaveMsg)
+	}
+	if !found {
+		return false
+	}
+
+	leaveMsg := models.NewMessage(models.MessageTypeLeave, username, content, room)
+	leaveMsg.Color = h.userColors[username]
+	h.broadcastToRoom(leaveMsg, room)
+	h.sendUserList(room)
+	return true
+}
+
+// addressesOf returns the addresses a user is connected from
+func (h *Hub) addressesOf(username string) []string {
+	canonical := canonicalName(username)
+	var ips []string
+	for client := range h.clients {
+		if canonicalName(client.Username) == canonical && client.ip != "" && !slices.Contains(ips, client.ip) {
+			ips = append(ips, client.ip)
+		}
+	}
+	return ips
+}
+
+// saveRoomStates writes room state to disk after a change
+func (h *Hub) saveRoomStates() {
+	if err := h.roomStates.Save(); err != nil {
+		log.Printf("⚠️ Failed to save room state: %v", err)
+	}
+}
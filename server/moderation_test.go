@@ -0,0 +1,132 @@
+package server
+
+import (
+	"slices"
+	"terminal-chat/models"
+	"testing"
+)
+
+// moderate sends a moderation command for room
+func (c *testConn) moderate(room, action, user string) {
+	c.t.Helper()
+	msg := models.NewMessage(models.MessageTypeModerate, "", "", room)
+	msg.Action, msg.User = action, user
+	c.send(msg)
+}
+
+// roleIn returns the role a user list gives username, "" for members
+func roleIn(list models.Message, username string) string {
+	i := slices.IndexFunc(list.Users, func(u models.User) bool { return u.Username == username })
+	if i < 0 {
+		return "absent"
+	}
+	return list.Users[i].Role
+}
+
+// dialOwner registers username and connects with it, creating room
+func (s *testServer) dialOwner(t *testing.T, username, room string) *testConn {
+	t.Helper()
+	token := s.register(t, username)
+	c := s.dial(t, "username="+username+"&room="+room+"&token="+token)
+	list := c.nextWhere(models.MessageTypeUserList, func(msg models.Message) bool { return msg.Room == room })
+	if role := roleIn(list, username); role != models.RoleOwner {
+		t.Fatalf("%s created %s but is %q", username, room, role)
+	}
+	return c
+}
+
+func TestGuestsCannotOwnRooms(t *testing.T) {
+	srv := newTestServer(t, DefaultConfig())
+	guest := srv.dial(t, "username=alex&room=r")
+	if role := roleIn(guest.next(models.MessageTypeUserList), "alex"); role != "" {
+		t.Fatalf("a guest who created a room is %q, want a member", role)
+	}
+
+	topic := models.NewMessage(models.MessageTypeTopic, "", "mine now", "r")
+	topic.Action = models.RoomFieldTopic
+	topic.ClientID = "t1"
+	guest.send(topic)
+	if got := guest.next(models.MessageTypeError); got.Code != models.ErrCodeNotAllowed {
+		t.Errorf("a guest changed the topic: %+v", got)
+	}
+}
+
+func TestOnlyAccountsCanBeModerators(t *testing.T) {
+	srv := newTestServer(t, DefaultConfig())
+	owner := srv.dialOwner(t, "owner", "r")
+	guest := srv.dial(t, "username=bob&room=r")
+	guest.joined("r")
+
+	owner.moderate("r", models.ModOp, "bob")
+	if got := owner.next(models.MessageTypeError); got.Code != models.ErrCodeNotAllowed {
+		t.Fatalf("a guest was made a moderator: %+v", got)
+	}
+
+	token := srv.register(t, "Carol")
+	carol := srv.dial(t, "username=Carol&room=r&token="+token)
+	carol.joined("r")
+	owner.moderate("r", models.ModOp, "Carol")
+	list := owner.nextWhere(models.MessageTypeUserList, func(msg models.Message) bool {
+		return roleIn(msg, "Carol") != ""
+	})
+	if role := roleIn(list, "Carol"); role != models.RoleModerator {
+		t.Errorf("Carol is %q after /op", role)
+	}
+}
+
+func TestModerationTargetsMatchAnyCase(t *testing.T) {
+	srv := newTestServer(t, DefaultConfig())
+	owner := srv.dialOwner(t, "owner", "r")
+	alex := srv.dial(t, "username=alex&room=r")
+	alex.joined("r")
+
+	owner.moderate("r", models.ModMute, "ALEX")
+	owner.nextWhere(models.MessageTypeSystem, func(msg models.Message) bool { return msg.Room == "r" })
+	chat := models.NewMessage(models.MessageTypeChat, "", "still here?", "r")
+	chat.ClientID = "c1"
+	alex.send(chat)
+	if got := alex.next(models.MessageTypeError); got.Code != models.ErrCodeMuted {
+		t.Fatalf("/mute ALEX did not mute alex: %+v", got)
+	}
+
+	owner.moderate("r", models.ModUnmute, "Alex")
+	owner.nextWhere(models.MessageTypeSystem, func(msg models.Message) bool { return msg.Room == "r" })
+	chat.ClientID = "c2"
+	alex.send(chat)
+	if got := alex.next(models.MessageTypeAck); got.ClientID != "c2" {
+		t.Fatalf("/unmute Alex did not unmute alex: %+v", got)
+	}
+
+	owner.moderate("r", models.ModKick, "Alex")
+	if got := alex.next(models.MessageTypeLeave); got.Room != "r" {
+		t.Fatalf("/kick Alex did not kick alex: %+v", got)
+	}
+}
+
+func TestOpIgnoresCase(t *testing.T) {
+	srv := newTestServer(t, DefaultConfig())
+	owner := srv.dialOwner(t, "owner", "r")
+	token := srv.register(t, "Carol")
+	carol := srv.dial(t, "username=Carol&room=r&token="+token)
+	carol.joined("r")
+
+	owner.moderate("r", models.ModOp, "carol")
+	list := owner.nextWhere(models.MessageTypeUserList, func(msg models.Message) bool {
+		return roleIn(msg, "Carol") != ""
+	})
+	if role := roleIn(list, "Carol"); role != models.RoleModerator {
+		t.Fatalf("Carol is %q after /op carol", role)
+	}
+
+	owner.moderate("r", models.ModOp, "CAROL")
+	if got := owner.next(models.MessageTypeError); got.Code != models.ErrCodeBadMessage {
+		t.Fatalf("a second /op was not refused: %+v", got)
+	}
+	owner.moderate("r", models.ModDeop, "cArOl")
+	list = owner.nextWhere(models.MessageTypeUserList, func(msg models.Message) bool {
+		return roleIn(msg, "Carol") == ""
+	})
+	if role := roleIn(list, "Carol"); role != "" {
+		t.Errorf("Carol is %q after one /deop", role)
+	}
+}
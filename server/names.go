@@ -0,0 +1,118 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// guestName is given to connections that do not ask for a name
+const guestName = "guest"
+
+// reservedNames cannot be taken by users, in any case or Unicode form, so
+// nobody can pass themselves off as the server
+var reservedNames = map[string]bool{
+	canonicalName("system"):    true,
+	canonicalName("server"):    true,
+	canonicalName("anonymous"): true,
+}
+
+// Name errors, refused before a connection is upgraded
+var (
+	ErrReservedName = errors.New("that name is reserved")
+	ErrNameSpaces   = errors.New("username must not contain spaces or control characters")
+)
+
+// canonicalName is the form two names are compared in: case folded and
+// NFKC normalised, so "Alex", "ALEX" and "Ａｌｅｘ" are the same person
+func canonicalName(name string) string {
+	return norm.NFKC.String(cases.Fold().String(norm.NFKC.String(name)))
+}
+
+// validateUsername checks a name anyone could be given, registered or not
+func validateUsername(name string) error {
+	if name == "" || utf8.RuneCountInString(name) > maxUsernameLength {
+		return ErrInvalidUsername
+	}
+	for _, r := range name {
+		if unicode.IsSpace(r) || unicode.IsControl(r) || r == utf8.RuneError {
+			return ErrNameSpaces
+		}
+	}
+	if reservedNames[canonicalName(name)] {
+		return ErrReservedName
+	}
+	return nil
+}
+
+// uniqueName returns the name a new connection goes by: the one it asked for
+// unless somebody else online holds it in some case or form, in which case a
+// number is added. Registered and reserved names are never handed out.
+func (h *Hub) uniqueName(client *Client) string {
+	if !h.nameTaken(client.Username, client) {
+		return client.Username
+	}
+
+	base := []rune(client.Username)
+	for n := 2; ; n++ {
+		suffix := strconv.Itoa(n)
+		candidate := string(base[:min(len(base), maxUsernameLength-len(suffix))]) + suffix
+		if h.nameTaken(candidate, client) || reservedNames[canonicalName(candidate)] {
+			continue
+		}
+		if _, registered := h.accounts.Holder(candidate); registered {
+			continue
+		}
+		return candidate
+	}
+}
+
+// nameTaken reports whether another user online goes by name. The same
+// account on a second device may share it, as may a reconnect that presents
+// the resume key of a connection the server has not noticed is dead yet.
+func (h *Hub) nameTaken(name string, client *Client) bool {
+	canonical := canonicalName(name)
+	for other := range h.clients {
+		if canonicalName(other.Username) != canonical {
+			continue
+		}
+		sameAccount := client.registered && other.registered && other.Username == name
+		sameSession := client.resume != "" && other.resume == client.resume
+		if !sameAccount && !sameSession {
+			return true
+		}
+	}
+	return false
+}
+
+// evictGuests disconnects guests going by the name of an account whose
+// holder just connected: they took it before it was registered, and the
+// account comes first
+func (h *Hub) evictGuests(client *Client) {
+	canonical := canonicalName(client.Username)
+	for other := range h.clients {
+		if other.registered || canonicalName(other.Username) != canonical {
+			continue
+		}
+		log.Printf("🪪 %s is registered now, disconnecting the guest using it", other.Username)
+		for room := range other.rooms {
+			h.eject(room, other.Username, "left the chat (the name now belongs to an account)")
+		}
+		// A policy close also stops the guest's client from reconnecting
+		other.closeCode = websocket.ClosePolicyViolation
+		other.closeReason = "name registered by an account"
+		h.dropClient(other)
+	}
+}
+
+// renameNotice tells a client why it got a different name than it asked for
+func renameNotice(asked, given string) string {
+	return fmt.Sprintf("%s is already in use, so you are %s.", asked, given)
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"terminal-chat/models"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestCanonicalName(t *testing.T) {
+	for _, tc := range []struct{ a, b string }{
+		{"Alex", "alex"},
+		{"ＡLEX", "alex"},
+		{"Straße", "STRASSE"},
+	} {
+		if canonicalName(tc.a) != canonicalName(tc.b) {
+			t.Errorf("%q and %q should match", tc.a, tc.b)
+		}
+	}
+	if canonicalName("alex") == canonicalName("alex2") {
+		t.Error("alex and alex2 should differ")
+	}
+}
+
+func TestValidateUsername(t *testing.T) {
+	for _, name := range []string{"alice", "Zoë", strings.Repeat("x", maxUsernameLength)} {
+		if err := validateUsername(name); err != nil {
+			t.Errorf("%q: unexpected error %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "a b", "tab\tname", "SYSTEM", "Server", strings.Repeat("x", maxUsernameLength+1)} {
+		if validateUsername(name) == nil {
+			t.Errorf("%q should be refused", name)
+		}
+	}
+}
+
+func TestTakenNameIsSuffixed(t *testing.T) {
+	srv := newTestServer(t, DefaultConfig())
+	alex := srv.dial(t, "username=Alex&room=r")
+	if got := alex.next(models.MessageTypeWelcome).Username; got != "Alex" {
+		t.Fatalf("first connection named %q, want Alex", got)
+	}
+	alex.joined("r")
+
+	other := srv.dial(t, "username=alex&room=r")
+	welcome := other.next(models.MessageTypeWelcome)
+	if welcome.Username != "alex2" {
+		t.Fatalf("second connection named %q, want alex2", welcome.Username)
+	}
+	if welcome.Content == "" {
+		t.Error("the renamed connection was not told why")
+	}
+	other.joined("r")
+
+	// The renamed connection is rate limited and can post under its new name
+	other.chat("r", "hello")
+	got := alex.nextWhere(models.MessageTypeChat, func(msg models.Message) bool { return msg.Content == "hello" })
+	if got.Username != "alex2" {
+		t.Errorf("message posted as %q, want alex2", got.Username)
+	}
+}
+
+func TestSuffixKeepsNamesShort(t *testing.T) {
+	srv := newTestServer(t, DefaultConfig())
+	long := strings.Repeat("a", maxUsernameLength)
+	srv.dial(t, "username="+long+"&room=r").next(models.MessageTypeWelcome)
+
+	got := srv.dial(t, "username="+long+"&room=r").next(models.MessageTypeWelcome).Username
+	if want := strings.Repeat("a", maxUsernameLength-1) + "2"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestResumeKeepsName(t *testing.T) {
+	srv := newTestServer(t, DefaultConfig())
+	srv.dial(t, "username=alex&room=r&resume=key1").next(models.MessageTypeWelcome)
+
+	// The server has not noticed the first connection died yet
+	if got := srv.dial(t, "username=alex&room=r&resume=key1").next(models.MessageTypeWelcome).Username; got != "alex" {
+		t.Errorf("reconnect with the resume key named %q, want alex", got)
+	}
+	if got := srv.dial(t, "username=alex&room=r&resume=other").next(models.MessageTypeWelcome).Username; got != "alex2" {
+		t.Errorf("another session named %q, want alex2", got)
+	}
+}
+
+func TestRefusedNames(t *testing.T) {
+	srv := newTestServer(t, DefaultConfig())
+	srv.register(t, "Bob")
+
+	for query, want := range map[string]int{
+		"username=SYSTEM":    http.StatusConflict,
+		"username=a%20b":     http.StatusBadRequest,
+		"username=bob":       http.StatusUnauthorized, // Registered as Bob
+		"username=Bob":       http.StatusUnauthorized, // No token
+		"username=Anonymous": http.StatusConflict,
+	} {
+		url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws?" + query
+		_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+		if err == nil {
+			t.Errorf("%s: connected, want status %d", query, want)
+			continue
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != want {
+			t.Errorf("%s: status %d (%s), want %d", query, resp.StatusCode, body, want)
+		}
+	}
+}
+
+func TestAccountTakesItsNameFromAGuest(t *testing.T) {
+	srv := newTestServer(t, DefaultConfig())
+	bob := srv.dial(t, "username=bob&room=r")
+	bob.joined("r")
+	guest := srv.dial(t, "username=dave&room=r")
+	guest.joined("r")
+
+	token := srv.register(t, "Dave")
+	dave := srv.dial(t, "username=Dave&room=r&token="+token)
+	if got := dave.next(models.MessageTypeWelcome).Username; got != "Dave" {
+		t.Fatalf("the account holder was named %q, want Dave", got)
+	}
+	dave.joined("r")
+
+	select {
+	case err := <-guest.closed:
+		if !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+			t.Errorf("the guest was closed with %v, want a policy close", err)
+		}
+	case <-time.After(waitTime):
+		t.Fatal("the guest using the account's name is still connected")
+	}
+	bob.nextWhere(models.MessageTypeLeave, func(msg models.Message) bool { return msg.Username == "dave" })
+
+	// Only the account holder goes by the name now
+	dm := models.NewMessage(models.MessageTypeDirect, "", "hi dave", "")
+	dm.Recipient = "dave"
+	bob.send(dm)
+	if got := dave.next(models.MessageTypeDirect); got.Content != "hi dave" {
+		t.Errorf("direct message to the account gave %+v", got)
+	}
+}
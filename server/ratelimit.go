@@ -0,0 +1,226 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"terminal-chat/models"
+	"time"
+)
+
+// strikeWindow is how long a user must stay within the limits before
+// earlier breaches are forgotten
+const strikeWindow = time.Minute
+
+// RateLimit configures a token bucket: Burst frames at once, refilled at
+// Rate frames per second
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// String renders the limit as rate/burst, the form its flags take
+func (r RateLimit) String() string {
+	return strconv.FormatFloat(r.Rate, 'g', -1, 64) + "/" + strconv.Itoa(r.Burst)
+}
+
+// Set parses a limit written as rate/burst, such as 0.5/4
+func (r *RateLimit) Set(value string) error {
+	rate, burst, ok := strings.Cut(value, "/")
+	if !ok {
+		return fmt.Errorf("want rate/burst, such as 1/8")
+	}
+	parsedRate, err := strconv.ParseFloat(rate, 64)
+	if err != nil {
+		return fmt.Errorf("bad rate %q", rate)
+	}
+	parsedBurst, err := strconv.Atoi(burst)
+	if err != nil {
+		return fmt.Errorf("bad burst %q", burst)
+	}
+	r.Rate, r.Burst = parsedRate, parsedBurst
+	return nil
+}
+
+// model is the limit as announced to clients
+func (r RateLimit) model() models.Rate {
+	return models.Rate{PerSecond: r.Rate, Burst: r.Burst}
+}
+
+// validate checks that the bucket ever lets anything through
+func (r RateLimit) validate(name string) error {
+	if r.Rate <= 0 || r.Burst < 1 {
+		return fmt.Errorf("-%s (%s) needs a positive rate and a burst of at least 1", name, r)
+	}
+	return nil
+}
+
+// bucket is the state of one token bucket
+type bucket struct {
+	tokens float64
+	last   time.Time // Last refill; zero for a full, unused bucket
+}
+
+// take refills the bucket for the time passed and spends a token if one is left
+func (b *bucket) take(limit RateLimit, now time.Time) bool {
+	if b.last.IsZero() {
+		b.tokens = float64(limit.Burst)
+	} else {
+		b.tokens = min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// verdict is the limiter's ruling on one inbound frame
+type verdict int
+
+const (
+	allowed verdict = iota // Within the limits
+	dropped                // Over a limit; the frame is dropped
+	warned                 // First breach: dropped, and the user is warned
+	muted                  // Repeated breach: dropped, and the user is muted
+	kicked                 // Flooding: the connection is closed
+)
+
+// userLimits holds one user's buckets and breach record, shared by all of
+// their connections so opening more does not raise the limits
+type userLimits struct {
+	messages   bucket
+	gifs       bucket
+	joins      bucket
+	strikes    int       // Breaches since the user last stayed within the limits
+	lastStrike time.Time // When the last breach happened
+	mutedUntil time.Time // Messages and GIFs are dropped until then
+	conns      int       // Open connections of the user
+}
+
+// limiter enforces the rate limits of every connection. Each connection
+// is limited in raw frames; each user, across all their connections, in
+// messages, GIFs and joins. readPumps call it concurrently.
+type limiter struct {
+	mu    sync.Mutex
+	cfg   *Config
+	users map[string]*userLimits
+}
+
+// newLimiter creates a limiter with the limits in cfg
+func newLimiter(cfg *Config) *limiter {
+	return &limiter{
+		cfg:   cfg,
+		users: make(map[string]*userLimits),
+	}
+}
+
+// open starts tracking a connection of a user. Users are tracked by
+// canonical name, the form in which names are unique.
+func (l *limiter) open(username string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	canonical := canonicalName(username)
+	user := l.users[canonical]
+	if user == nil {
+		user = &userLimits{}
+		l.users[canonical] = user
+	}
+	user.conns++
+}
+
+// close stops tracking a connection, and forgets users that are gone and
+// have nothing left on their record, so a reconnect cannot shake off a mute
+func (l *limiter) close(username string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if user := l.users[canonicalName(username)]; user != nil {
+		user.conns--
+	}
+	now := time.Now()
+	for name, user := range l.users {
+		if user.conns <= 0 && now.After(user.mutedUntil) && now.Sub(user.lastStrike) > strikeWindow {
+			delete(l.users, name)
+		}
+	}
+}
+
+// check rules on a frame of type typ arriving on a connection
+func (l *limiter) check(client *Client, typ models.MessageType) verdict {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// No client sends this much; only scripts do
+	if !client.frames.take(l.cfg.FrameLimit, now) {
+		log.Printf("🚨 %s exceeded %s frames per second, disconnecting", client.Username, l.cfg.FrameLimit)
+		return kicked
+	}
+
+	user := l.users[canonicalName(client.Username)]
+	ok := true
+	switch typ {
+	case models.MessageTypeChat, models.MessageTypeDirect, models.MessageTypeEdit,
+		models.MessageTypeDelete, models.MessageTypeReaction, models.MessageTypeTopic,
+		models.MessageTypeAccess, models.MessageTypeFileOffer, models.MessageTypeFileRequest:
+		ok = now.After(user.mutedUntil) && user.messages.take(l.cfg.MessageLimit, now)
+	case models.MessageTypeGIF:
+		ok = now.After(user.mutedUntil) && user.gifs.take(l.cfg.GIFLimit, now)
+	case models.MessageTypeJoinRoom, models.MessageTypePartRoom:
+		ok = user.joins.take(l.cfg.JoinLimit, now)
+	}
+	if ok {
+		return allowed
+	}
+
+	// Frames dropped during a mute are not new breaches
+	if now.Before(user.mutedUntil) {
+		return dropped
+	}
+	if now.Sub(user.lastStrike) > strikeWindow {
+		user.strikes = 0
+	}
+	user.strikes++
+	user.lastStrike = now
+
+	switch {
+	case user.strikes >= l.cfg.MuteAfter:
+		user.mutedUntil = now.Add(l.cfg.MuteDuration)
+		log.Printf("🔇 %s muted for %s after %d rate limit breaches", client.Username, l.cfg.MuteDuration, user.strikes)
+		return muted
+	case user.strikes == 1:
+		log.Printf("🐢 %s hit the %s rate limit, warning", client.Username, typ)
+		return warned
+	}
+	return dropped
+}
+
+// handleLimited tells a client about a frame the limiter dropped: a notice
+// when the user is warned or muted, and an error for the message itself
+// so the client can mark it as not sent
+func (h *Hub) handleLimited(env *envelope) {
+	client := env.client
+
+	switch env.verdict {
+	case warned:
+		h.sendNotice(client, "🐢 Slow down - you are sending too fast. Messages over the limit are dropped.")
+	case muted:
+		h.sendNotice(client, fmt.Sprintf("🔇 You are muted for %s for flooding.", h.cfg.MuteDuration))
+	}
+
+	msg, err := models.MessageFromJSON(env.data)
+	if err == nil && msg.ClientID != "" {
+		h.rejectMessage(client, msg, models.ErrCodeRateLimited, "")
+	}
+}
+
+// sendNotice sends a system message to one client
+func (h *Hub) sendNotice(client *Client, content string) {
+	notice := models.NewMessage(models.MessageTypeSystem, "system", content, "")
+	h.sendToClient(client, notice)
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"terminal-chat/models"
+	"testing"
+)
+
+func TestLimiterWarnsThenMutes(t *testing.T) {
+	cfg := DefaultConfig()
+	l := newLimiter(cfg)
+	alice := &Client{Username: "alice"}
+	l.open(alice.Username)
+
+	for i := range cfg.MessageLimit.Burst {
+		if v := l.check(alice, models.MessageTypeChat); v != allowed {
+			t.Fatalf("message %d of the burst got verdict %d", i+1, v)
+		}
+	}
+	want := []verdict{warned, dropped, muted, dropped}
+	for i, w := range want {
+		if v := l.check(alice, models.MessageTypeChat); v != w {
+			t.Fatalf("message %d over the burst got verdict %d, want %d", i+1, v, w)
+		}
+	}
+	if v := l.check(alice, models.MessageTypeGIF); v != dropped {
+		t.Errorf("a GIF during a mute got verdict %d", v)
+	}
+	if v := l.check(alice, models.MessageTypeJoinRoom); v != allowed {
+		t.Errorf("a join during a mute got verdict %d", v)
+	}
+}
+
+func TestLimiterSharesBucketsAcrossNameForms(t *testing.T) {
+	cfg := DefaultConfig()
+	l := newLimiter(cfg)
+	first, second := &Client{Username: "Alex"}, &Client{Username: "alex"}
+	l.open(first.Username)
+	l.open(second.Username)
+
+	for range cfg.MessageLimit.Burst {
+		l.check(first, models.MessageTypeChat)
+	}
+	if v := l.check(second, models.MessageTypeChat); v == allowed {
+		t.Error("a second connection under another form of the name got a fresh bucket")
+	}
+
+	// The record outlives the connections while a mute or strike is on it
+	l.close(first.Username)
+	l.close(second.Username)
+	l.open(second.Username)
+	if v := l.check(second, models.MessageTypeChat); v == allowed {
+		t.Error("reconnecting shook off the limit")
+	}
+}
+
+func TestLimiterKicksFloods(t *testing.T) {
+	cfg := DefaultConfig()
+	l := newLimiter(cfg)
+	bot := &Client{Username: "bot"}
+	l.open(bot.Username)
+
+	for range cfg.FrameLimit.Burst {
+		if v := l.check(bot, models.MessageTypeTypingStart); v == kicked {
+			t.Fatal("kicked within the frame burst")
+		}
+	}
+	if v := l.check(bot, models.MessageTypeTypingStart); v != kicked {
+		t.Errorf("a frame over the burst got verdict %d", v)
+	}
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"terminal-chat/models"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// maxEmojiLength bounds a reaction in bytes and maxEmojiRunes in code
+	// points; flags and families take several of them
+	maxEmojiLength = 32
+	maxEmojiRunes  = 8
+	// maxReactionKinds caps how many different emoji one message can carry
+	maxReactionKinds = 20
+)
+
+// react toggles the sender's reaction on a room message and broadcasts the
+// message's updated tally
+func (h *Hub) react(client *Client, msg *models.Message) {
+	room, ok := h.resolveRoom(client, msg.Room)
+	if !ok {
+		h.rejectMessage(client, msg, models.ErrCodeWrongRoom,
+			fmt.Sprintf("You are not a member of room '%s'", msg.Room))
+		return
+	}
+	if !h.checkPosting(client, msg, room) {
+		return
+	}
+
+	emoji := strings.TrimSpace(msg.Emoji)
+	if !validEmoji(emoji) {
+		h.rejectMessage(client, msg, models.ErrCodeBadMessage, "Reactions must be a single emoji")
+		return
+	}
+
+	target, err := h.store.Find(room, msg.Target)
+	if errors.Is(err, ErrMessageNotFound) || (err == nil && target.Deleted) {
+		h.rejectMessage(client, msg, models.ErrCodeNotFound, "That message no longer exists")
+		return
+	}
+	if err != nil {
+		log.Printf("⚠️ Failed to look up message %s in room '%s': %v", msg.Target, room, err)
+		h.rejectMessage(client, msg, models.ErrCodeNotFound, "That message could not be loaded")
+		return
+	}
+
+	reactions, ok := toggleReaction(target.Reactions, emoji, client.Username)
+	if !ok {
+		h.rejectMessage(client, msg, models.ErrCodeNotAllowed,
+			fmt.Sprintf("Messages can carry at most %d different reactions", maxReactionKinds))
+		return
+	}
+	target.Reactions = reactions
+
+	if err := h.store.Update(target); err != nil {
+		log.Printf("⚠️ Failed to store reaction on message %s: %v", target.ID, err)
+		h.rejectMessage(client, msg, models.ErrCodeNotFound, "That message could not be changed")
+		return
+	}
+
+	// The whole tally goes out so clients never have to count themselves
+	event := models.NewMessage(models.MessageTypeReaction, client.Username, "", room)
+	event.ClientID = msg.ClientID
+	event.Target = target.ID
+	event.Emoji = emoji
+	event.Reactions = target.Reactions
+	h.broadcastToRoom(event, room)
+	h.acknowledge(client, event)
+}
+
+// validEmoji reports whether a reaction is one short emoji: symbols, and
+// the joiners, variation selectors, skin tones, keycaps and tags emoji are
+// built from, but no letters, punctuation or spaces
+func validEmoji(emoji string) bool {
+	if emoji == "" || len(emoji) > maxEmojiLength || utf8.RuneCountInString(emoji) > maxEmojiRunes ||
+		!utf8.ValidString(emoji) {
+		return false
+	}
+
+	keycap := strings.ContainsRune(emoji, '\u20e3')
+	symbol := false
+	for _, r := range emoji {
+		switch {
+		case unicode.Is(unicode.So, r):
+			symbol = true
+		case keycap && (r == '#' || r == '*' || ('0' <= r && r <= '9')):
+			symbol = true
+		case 0x1f3fb <= r && r <= 0x1f3ff: // Skin tones
+		case r == 0x200d: // Zero width joiner
+		case r == 0xfe0e || r == 0xfe0f: // Text and emoji presentation
+		case r == 0x20e3: // Keycap
+		case 0xe0020 <= r && r <= 0xe007f: // Tags spelling out subdivision flags
+		default:
+			return false
+		}
+	}
+	return symbol
+}
+
+// toggleReaction adds the user's emoji to a tally, or takes it back if the
+// user already reacted with it. It reports false when the emoji would be
+// one kind too many.
+func toggleReaction(reactions []models.Reaction, emoji, username string) ([]models.Reaction, bool) {
+	reactions = slices.Clone(reactions)
+
+	i := slices.IndexFunc(reactions, func(r models.Reaction) bool { return r.Emoji == emoji })
+	if i < 0 {
+		if len(reactions) >= maxReactionKinds {
+			return nil, false
+		}
+		return append(reactions, models.Reaction{Emoji: emoji, Count: 1, Users: []string{username}}), true
+	}
+
+	reaction := reactions[i]
+	if j := slices.Index(reaction.Users, username); j >= 0 {
+		reaction.Users = slices.Delete(slices.Clone(reaction.Users), j, j+1)
+	} else {
+		reaction.Users = append(slices.Clone(reaction.Users), username)
+	}
+	reaction.Count = len(reaction.Users)
+
+	if reaction.Count == 0 {
+		return slices.Delete(reactions, i, i+1), true
+	}
+	reactions[i] = reaction
+	return reactions, true
+}
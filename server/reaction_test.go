@@ -0,0 +1,57 @@
+package server
+
+import (
+	"terminal-chat/models"
+	"testing"
+)
+
+func TestValidEmoji(t *testing.T) {
+	for emoji, want := range map[string]bool{
+		"👍":         true,
+		"❤️":        true,
+		"👍🏽":        true,
+		"👩‍💻":       true,
+		"👨‍👩‍👧‍👦":   true,
+		"🇫🇷":        true,
+		"🏴󠁧󠁢󠁳󠁣󠁴󠁿":   true,
+		"1️⃣":       true,
+		"★":         true,
+		"":          false,
+		"lol":       false,
+		"1":         false,
+		"#":         false,
+		"👍 👍":       false,
+		"a👍":        false,
+		"👍!":        false,
+		"→":         false,
+		"‍":         false,
+		"🔥🔥🔥🔥🔥🔥🔥🔥🔥": false,
+	} {
+		if got := validEmoji(emoji); got != want {
+			t.Errorf("validEmoji(%q) = %v, want %v", emoji, got, want)
+		}
+	}
+}
+
+func TestReactionsAreValidated(t *testing.T) {
+	srv := newTestServer(t, DefaultConfig())
+	alice := srv.dial(t, "username=alice&room=r")
+	alice.joined("r")
+	alice.chat("r", "react to this")
+	target := alice.next(models.MessageTypeChat)
+
+	react := func(emoji string) {
+		msg := models.NewMessage(models.MessageTypeReaction, "", "", "r")
+		msg.Target, msg.Emoji, msg.ClientID = target.ID, emoji, emoji
+		alice.send(msg)
+	}
+
+	react("totally-not-an-emoji")
+	if got := alice.next(models.MessageTypeError); got.Code != models.ErrCodeBadMessage {
+		t.Errorf("a word was taken as a reaction: %+v", got)
+	}
+	react("🎉")
+	if got := alice.next(models.MessageTypeReaction); len(got.Reactions) != 1 || got.Reactions[0].Emoji != "🎉" {
+		t.Errorf("an emoji reaction gave the tally %+v", got.Reactions)
+	}
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"terminal-chat/models"
+)
+
+// ReadMarks remembers how far each user has read in each room, as the seq
+// of the newest message their client has shown. Only the hub uses it, so
+// it has no lock of its own.
+type ReadMarks struct {
+	path  string                       // JSON file, "" to keep marks in memory only
+	marks map[string]map[string]uint64 // Room -> username -> seq
+	dirty bool                         // Changed since the last save
+}
+
+// NewReadMarks loads the read marks file, or starts empty when path is ""
+// or the file does not exist yet
+func NewReadMarks(path string) (*ReadMarks, error) {
+	r := &ReadMarks{
+		path:  path,
+		marks: make(map[string]map[string]uint64),
+	}
+	if path == "" {
+		return r, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &r.marks); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Get returns how far a user has read in a room, 0 for not at all
+func (r *ReadMarks) Get(room, username string) uint64 {
+	return r.marks[room][username]
+}
+
+// Set moves a user's read mark forward; marks never move back
+func (r *ReadMarks) Set(room, username string, seq uint64) {
+	if seq <= r.marks[room][username] {
+		return
+	}
+	if r.marks[room] == nil {
+		r.marks[room] = make(map[string]uint64)
+	}
+	r.marks[room][username] = seq
+	r.dirty = true
+}
+
+// Readers returns the users who have read a room up to seq, sorted
+func (r *ReadMarks) Readers(room string, seq uint64) []string {
+	var readers []string
+	for username, mark := range r.marks[room] {
+		if mark >= seq {
+			readers = append(readers, username)
+		}
+	}
+	slices.Sort(readers)
+	return readers
+}
+
+// Save writes the marks out if they changed since the last save
+func (r *ReadMarks) Save() error {
+	if r.path == "" || !r.dirty {
+		return nil
+	}
+	data, err := json.Marshal(r.marks)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(r.path, data, 0o644); err != nil {
+		return err
+	}
+	r.dirty = false
+	return nil
+}
+
+// markRead records how far a client has read in a room. Reports beyond the
+// room's latest message are cut back to it; reports for rooms the client
+// is not in are dropped.
+func (h *Hub) markRead(client *Client, msg *models.Message) {
+	room, ok := h.resolveRoom(client, msg.Room)
+	if !ok {
+		return
+	}
+	h.readMarks.Set(room, client.Username, min(msg.Seq, h.headSeq(room)))
+}
+
+// sendReadMark tells a joining client how far it read in the room before,
+// so it can mark where the new messages start
+func (h *Hub) sendReadMark(client *Client, room string) {
+	mark := h.readMarks.Get(room, client.Username)
+	// A mark past the head is left over from history that was lost since
+	if mark == 0 || mark > h.headSeq(room) {
+		return
+	}
+	msg := models.NewMessage(models.MessageTypeRead, "system", "", room)
+	msg.Seq = mark
+	h.sendToClient(client, msg)
+}
+
+// sendReceipts answers /seen: who has read a room up to a message, and
+// which of the room's current members have not
+func (h *Hub) sendReceipts(client *Client, msg *models.Message) {
+	room, ok := h.resolveRoom(client, msg.Room)
+	if !ok {
+		h.rejectMessage(client, msg, models.ErrCodeWrongRoom,
+			fmt.Sprintf("You are not a member of room '%s'", msg.Room))
+		return
+	}
+	if msg.Seq == 0 || msg.Seq > h.headSeq(room) {
+		h.rejectMessage(client, msg, models.ErrCodeNotFound, "That message no longer exists")
+		return
+	}
+
+	reply := models.NewMessage(models.MessageTypeReceipts, "system", "", room)
+	reply.Target = msg.Target
+	reply.Seq = msg.Seq
+	reply.ReadBy = h.readMarks.Readers(room, msg.Seq)
+	for member := range h.rooms[room] {
+		if !slices.Contains(reply.ReadBy, member.Username) && !slices.Contains(reply.Unread, member.Username) {
+			reply.Unread = append(reply.Unread, member.Username)
+		}
+	}
+	slices.Sort(reply.Unread)
+	h.sendToClient(client, reply)
+}
+
+// saveReadMarks writes read marks that changed to disk
+func (h *Hub) saveReadMarks() {
+	if err := h.readMarks.Save(); err != nil {
+		log.Printf("⚠️ Failed to save read marks: %v", err)
+	}
+}
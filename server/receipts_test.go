@@ -0,0 +1,65 @@
+package server
+
+import (
+	"strings"
+	"terminal-chat/models"
+	"testing"
+)
+
+// read reports that the connection has shown room up to seq, and waits
+// until the hub has taken the report in
+func (c *testConn) read(room string, seq uint64) {
+	c.t.Helper()
+	msg := models.NewMessage(models.MessageTypeRead, "", "", room)
+	msg.Seq = seq
+	c.send(msg)
+	c.directory()
+}
+
+// receipts asks who has read room up to seq
+func (c *testConn) receipts(room string, seq uint64) models.Message {
+	c.t.Helper()
+	msg := models.NewMessage(models.MessageTypeReceipts, "", "", room)
+	msg.Seq = seq
+	c.send(msg)
+	return c.next(models.MessageTypeReceipts)
+}
+
+func TestReadMarksAndReceipts(t *testing.T) {
+	srv := newTestServer(t, DefaultConfig())
+	alice := srv.dial(t, "username=alice&room=r")
+	alice.joined("r")
+	bob := srv.dial(t, "username=bob&room=r")
+	bob.joined("r")
+	carol := srv.dial(t, "username=carol&room=r")
+	carol.joined("r")
+
+	alice.chat("r", "one")
+	alice.chat("r", "two")
+	second := carol.nextWhere(models.MessageTypeChat, func(msg models.Message) bool { return msg.Content == "two" })
+
+	bob.read("r", second.Seq)
+	carol.read("r", second.Seq-1)
+	got := alice.receipts("r", second.Seq)
+	if by, unread := strings.Join(got.ReadBy, ","), strings.Join(got.Unread, ","); by != "bob" || unread != "alice,carol" {
+		t.Fatalf("read by %q, unread by %q", by, unread)
+	}
+
+	// Reports past the newest message count as reading up to it
+	carol.read("r", second.Seq+100)
+	if got := alice.receipts("r", second.Seq); strings.Join(got.ReadBy, ",") != "bob,carol" {
+		t.Fatalf("read by %v", got.ReadBy)
+	}
+	beyond := models.NewMessage(models.MessageTypeReceipts, "", "", "r")
+	beyond.Seq = second.Seq + 1
+	alice.send(beyond)
+	alice.refused(models.ErrCodeNotFound)
+
+	// Coming back, a client learns where it stopped reading
+	bob.conn.Close()
+	alice.nextWhere(models.MessageTypeLeave, func(msg models.Message) bool { return msg.Username == "bob" })
+	back := srv.dial(t, "username=bob&room=r")
+	if mark := back.next(models.MessageTypeRead); mark.Seq != second.Seq {
+		t.Fatalf("bob's read mark is %d, want %d", mark.Seq, second.Seq)
+	}
+}
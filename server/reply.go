@@ -0,0 +1,45 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"terminal-chat/models"
+)
+
+// maxQuoteLength is how many characters of a parent message a reply quotes
+const maxQuoteLength = 60
+
+// attachParent checks that a reply answers an existing message of the same
+// room and stamps the thread and quote from the stored parent. It rejects
+// the reply and returns false otherwise.
+func (h *Hub) attachParent(client *Client, msg *models.Message, room string) bool {
+	parent, err := h.store.Find(room, msg.ReplyTo)
+	if errors.Is(err, ErrMessageNotFound) || (err == nil && parent.Deleted) {
+		h.rejectMessage(client, msg, models.ErrCodeNotFound,
+			"The message you replied to does not exist in this room")
+		return false
+	}
+	if err != nil {
+		log.Printf("⚠️ Failed to look up message %s in room '%s': %v", msg.ReplyTo, room, err)
+		h.rejectMessage(client, msg, models.ErrCodeNotFound, "The message you replied to could not be loaded")
+		return false
+	}
+
+	msg.Thread = parent.ThreadRoot()
+	msg.Quote = &models.Quote{
+		Username: parent.Username,
+		Content:  excerpt(parent.Content, maxQuoteLength),
+	}
+	return true
+}
+
+// excerpt shortens text to at most n characters on a single line
+func excerpt(text string, n int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	return strings.TrimSpace(string(runes[:n-1])) + "…"
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"strings"
+	"terminal-chat/models"
+	"testing"
+)
+
+// reply answers the message parent in room
+func (c *testConn) reply(room, parent, content string) {
+	c.t.Helper()
+	msg := models.NewMessage(models.MessageTypeChat, "", content, room)
+	msg.ReplyTo = parent
+	c.send(msg)
+}
+
+func TestRepliesJoinTheThreadOfTheirParent(t *testing.T) {
+	srv := newTestServer(t, DefaultConfig())
+	alice := srv.dial(t, "username=alice&room=r")
+	alice.joined("r")
+	bob := srv.dial(t, "username=bob&room=r")
+	bob.joined("r")
+
+	alice.chat("r", "what should   we call the project? "+strings.Repeat("ideas welcome ", 10))
+	root := bob.next(models.MessageTypeChat)
+
+	bob.reply("r", root.ID, "terminal-chat")
+	first := alice.nextWhere(models.MessageTypeChat, func(msg models.Message) bool { return msg.Content == "terminal-chat" })
+	if first.Thread != root.ID || first.ReplyTo != root.ID {
+		t.Fatalf("reply in thread %q to %q, want both %q", first.Thread, first.ReplyTo, root.ID)
+	}
+	if first.Quote == nil || first.Quote.Username != "alice" ||
+		!strings.HasPrefix(first.Quote.Content, "what should we call") ||
+		len([]rune(first.Quote.Content)) > maxQuoteLength {
+		t.Fatalf("quote %+v", first.Quote)
+	}
+
+	// Replies to replies stay in the thread of the first message
+	alice.reply("r", first.ID, "works for me")
+	second := bob.nextWhere(models.MessageTypeChat, func(msg models.Message) bool { return msg.Content == "works for me" })
+	if second.Thread != root.ID || second.Quote == nil || second.Quote.Username != "bob" {
+		t.Fatalf("a reply to a reply is in thread %q quoting %+v", second.Thread, second.Quote)
+	}
+
+	bob.reply("r", "no-such-message", "hello?")
+	bob.refused(models.ErrCodeNotFound)
+}
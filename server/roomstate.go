@@ -0,0 +1,204 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"slices"
+	"terminal-chat/models"
+	"time"
+)
+
+// RoomState is what the server remembers about a room between restarts
+type RoomState struct {
+	Owner      string               `json:"owner,omitempty"`
+	Moderators []string             `json:"moderators,omitempty"`
+	Bans       []Ban                `json:"bans,omitempty"`
+	Mutes      map[string]time.Time `json:"mutes,omitempty"` // Muted users and when their mute ends
+
+	Topic       string    `json:"topic,omitempty"`
+	TopicBy     string    `json:"topic_by,omitempty"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at,omitzero"`
+
+	Private    bool                 `json:"private,omitempty"`    // Only invited users may join
+	Passphrase string               `json:"passphrase,omitempty"` // bcrypt hash of the join passphrase
+	Allowed    []string             `json:"allowed,omitempty"`    // Accounts let in by invite or passphrase
+	Invites    map[string]time.Time `json:"invites,omitempty"`    // Unused invite tokens and when they expire
+
+	guests map[string]bool // Resume keys of guest sessions let in; never saved
+}
+
+// Ban keeps a user, and optionally the addresses they used, out of a room
+type Ban struct {
+	Username string    `json:"username"`
+	IPs      []string  `json:"ips,omitempty"`
+	By       string    `json:"by"`
+	Reason   string    `json:"reason,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+// Role returns a user's role in the room. A nil state has only members.
+// Names match in any case or form, like bans.
+func (s *RoomState) Role(username string) string {
+	if s == nil {
+		return models.RoleMember
+	}
+	canonical := canonicalName(username)
+	switch {
+	case s.Owner != "" && canonicalName(s.Owner) == canonical:
+		return models.RoleOwner
+	case slices.ContainsFunc(s.Moderators, func(name string) bool { return canonicalName(name) == canonical }):
+		return models.RoleModerator
+	}
+	return models.RoleMember
+}
+
+// Banned returns the ban that keeps a user or address out, or nil. Names
+// match in any case or form, so a ban cannot be dodged by respelling.
+func (s *RoomState) Banned(username, ip string) *Ban {
+	if s == nil {
+		return nil
+	}
+	canonical := canonicalName(username)
+	for i, ban := range s.Bans {
+		if canonicalName(ban.Username) == canonical || (ip != "" && slices.Contains(ban.IPs, ip)) {
+			return &s.Bans[i]
+		}
+	}
+	return nil
+}
+
+// unban lifts the ban on a user and reports whether there was one
+func (s *RoomState) unban(username string) bool {
+	if s == nil {
+		return false
+	}
+	canonical := canonicalName(username)
+	i := slices.IndexFunc(s.Bans, func(ban Ban) bool { return canonicalName(ban.Username) == canonical })
+	if i < 0 {
+		return false
+	}
+	s.Bans = slices.Delete(s.Bans, i, i+1)
+	return true
+}
+
+// Muted reports whether a user may not post in the room right now. Mutes
+// are kept under canonical names.
+func (s *RoomState) Muted(username string, now time.Time) bool {
+	return s != nil && now.Before(s.Mutes[canonicalName(username)])
+}
+
+// Hidden reports whether joining the room takes an invite or passphrase,
+// which also keeps it out of the room directory
+func (s *RoomState) Hidden() bool {
+	return s != nil && (s.Private || s.Passphrase != "")
+}
+
+// allowed reports whether an account may join a hidden room without a key
+func (s *RoomState) allowed(username string) bool {
+	canonical := canonicalName(username)
+	return s != nil && slices.ContainsFunc(s.Allowed, func(name string) bool {
+		return canonicalName(name) == canonical
+	})
+}
+
+// allow lets an account into the room from now on
+func (s *RoomState) allow(username string) {
+	if !s.allowed(username) {
+		s.Allowed = append(s.Allowed, username)
+	}
+}
+
+// allowGuest lets a guest session back into the room until the server
+// restarts. Guests are known by session, not name, since anyone may take a
+// guest name once it is free.
+func (s *RoomState) allowGuest(resume string) {
+	if s.guests == nil {
+		s.guests = make(map[string]bool)
+	}
+	s.guests[resume] = true
+}
+
+// disallow takes back an account's invite
+func (s *RoomState) disallow(username string) {
+	canonical := canonicalName(username)
+	s.Allowed = slices.DeleteFunc(s.Allowed, func(name string) bool {
+		return canonicalName(name) == canonical
+	})
+}
+
+// empty reports whether the state holds nothing worth saving
+func (s *RoomState) empty() bool {
+	return s.Owner == "" && len(s.Moderators) == 0 && len(s.Bans) == 0 && len(s.Mutes) == 0 &&
+		s.Topic == "" && s.Description == "" && !s.Hidden() && len(s.Allowed) == 0
+}
+
+// RoomStates keeps the state of every room in a JSON file. Only the hub
+// uses it, so it has no lock of its own.
+type RoomStates struct {
+	path  string
+	rooms map[string]*RoomState
+}
+
+// NewRoomStates loads the room state file, or starts empty when it does
+// not exist yet
+func NewRoomStates(path string) (*RoomStates, error) {
+	s := &RoomStates{
+		path:  path,
+		rooms: make(map[string]*RoomState),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.rooms); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Find returns the state of a room, or nil when nothing is known about it
+func (s *RoomStates) Find(room string) *RoomState {
+	return s.rooms[room]
+}
+
+// Get returns the state of a room for changing, creating it if needed
+func (s *RoomStates) Get(room string) *RoomState {
+	state := s.rooms[room]
+	if state == nil {
+		state = &RoomState{}
+		s.rooms[room] = state
+	}
+	return state
+}
+
+// Save writes every room that has state worth keeping, dropping expired mutes
+func (s *RoomStates) Save() error {
+	now := time.Now()
+	for room, state := range s.rooms {
+		for username, until := range state.Mutes {
+			if now.After(until) {
+				delete(state.Mutes, username)
+			}
+		}
+		for token, expires := range state.Invites {
+			if now.After(expires) {
+				delete(state.Invites, token)
+			}
+		}
+		if state.empty() {
+			delete(s.rooms, room)
+		}
+	}
+
+	data, err := json.MarshalIndent(s.rooms, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data, 0o600)
+}
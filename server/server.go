@@ -1,24 +1,83 @@
 package server
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"terminal-chat/models"
 	"terminal-chat/utils"
 
 	"github.com/pterm/pterm"
 )
 
 // StartServer starts the WebSocket server
-func StartServer(port string) {
-	hub := NewHub()
+func StartServer(cfg *Config) {
+	port := cfg.Port
+
+	if err := cfg.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	tlsConfig, err := loadTLSConfig(cfg)
+	if err != nil {
+		log.Fatal("Failed to set up TLS: ", err)
+	}
+
+	store, err := OpenStore(cfg)
+	if err != nil {
+		log.Fatal("Failed to open message store: ", err)
+	}
+
+	accounts, err := NewAccountStore(filepath.Join(cfg.DataDir, "accounts.json"), cfg.SessionTTL)
+	if err != nil {
+		log.Fatal("Failed to load accounts: ", err)
+	}
+
+	// Read marks count seqs, which the memory backend forgets on restart
+	readMarksPath := ""
+	if cfg.HistoryBackend != "memory" {
+		readMarksPath = filepath.Join(cfg.DataDir, "read-marks.json")
+	}
+	readMarks, err := NewReadMarks(readMarksPath)
+	if err != nil {
+		log.Fatal("Failed to load read marks: ", err)
+	}
+
+	roomStates, err := NewRoomStates(filepath.Join(cfg.DataDir, "rooms.json"))
+	if err != nil {
+		log.Fatal("Failed to load room state: ", err)
+	}
+
+	files, err := NewFileStore(filepath.Join(cfg.DataDir, "files"), cfg.FileStoreSize, cfg.FileTTL)
+	if err != nil {
+		log.Fatal("Failed to set up file storage: ", err)
+	}
+
+	hub := NewHub(cfg, store, accounts, readMarks, roomStates, files)
 	go hub.Run()
 
 	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
-		ServeWS(hub, w, r)
+		ServeWS(hub, accounts, w, r)
 	})
 
+	// Account endpoints
+	http.HandleFunc("/register", handleRegister(accounts))
+	http.HandleFunc("/login", handleLogin(accounts))
+
+	// Room directory
+	http.HandleFunc("/rooms", handleRooms(hub))
+
+	// Prometheus metrics
+	http.HandleFunc("/metrics", handleMetrics(hub))
+
 	// Health check endpoint
 	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -28,18 +87,171 @@ func StartServer(port string) {
 	// Show server info
 	showServerInfo(port)
 
+	scheme := "ws"
+	if tlsConfig != nil {
+		scheme = "wss"
+	}
+
 	// CHANGED: Bind to all interfaces (0.0.0.0) instead of localhost
 	addr := "0.0.0.0:" + port
 	fmt.Printf("\n%s Server listening on %s\n",
 		utils.ColorSuccess("🚀"), utils.ColorBold("all interfaces:"+port))
 	fmt.Printf("%s WebSocket endpoint: %s\n",
-		utils.ColorInfo("🔗"), utils.ColorBold("ws://<your-ip>:"+port+"/ws"))
+		utils.ColorInfo("🔗"), utils.ColorBold(scheme+"://<your-ip>:"+port+"/ws"))
 	fmt.Printf("%s Local access: %s\n",
-		utils.ColorInfo("🏠"), utils.ColorBold("ws://localhost:"+port+"/ws"))
+		utils.ColorInfo("🏠"), utils.ColorBold(scheme+"://localhost:"+port+"/ws"))
+	if tlsConfig != nil {
+		fmt.Printf("%s Certificate fingerprint (SHA-256): %s\n",
+			utils.ColorInfo("🔒"), utils.ColorBold(certFingerprint(tlsConfig)))
+	}
 	fmt.Printf("%s Press %s to stop the server\n\n",
 		utils.ColorWarning("⚠️"), utils.ColorBold("Ctrl+C"))
 
-	log.Fatal(http.ListenAndServe(addr, nil))
+	httpServer := &http.Server{
+		Addr:      addr,
+		TLSConfig: tlsConfig,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if tlsConfig != nil {
+			serveErr <- httpServer.ListenAndServeTLS("", "")
+			return
+		}
+		serveErr <- httpServer.ListenAndServe()
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	select {
+	case err := <-serveErr:
+		store.Close()
+		log.Fatal(err)
+	case <-ctx.Done():
+		// A second Ctrl+C during shutdown kills the process immediately
+		stop()
+	}
+
+	shutdown(cfg, httpServer, hub, store)
+}
+
+// shutdown stops accepting connections, lets the hub say goodbye to every
+// client and flushes persistent state, all within cfg.ShutdownTimeout
+func shutdown(cfg *Config, httpServer *http.Server, hub *Hub, store MessageStore) {
+	fmt.Printf("\n%s Shutting down (up to %s)...\n",
+		utils.ColorWarning("🛑"), cfg.ShutdownTimeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	// Stop accepting new connections; upgraded WebSockets are not tracked
+	// by http.Server, so the hub closes those itself
+	if err := httpServer.Shutdown(ctx); err != nil {
+		log.Printf("⚠️ HTTP shutdown: %v", err)
+	}
+
+	if err := hub.Shutdown(ctx, "server shutting down"); err != nil {
+		log.Printf("⚠️ Not every client was flushed before the deadline: %v", err)
+	}
+
+	if err := store.Close(); err != nil {
+		log.Printf("⚠️ Failed to close message store: %v", err)
+	}
+
+	fmt.Printf("%s Server stopped\n", utils.ColorSuccess("👋"))
+}
+
+// handleRooms serves the public room directory as JSON
+func handleRooms(hub *Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		rooms := hub.Rooms()
+		if rooms == nil {
+			http.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rooms)
+	}
+}
+
+// handleRegister creates an account from a JSON credentials body
+func handleRegister(accounts *AccountStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		creds, ok := readCredentials(w, r)
+		if !ok {
+			return
+		}
+
+		err := accounts.Register(creds.Username, creds.Password)
+		switch {
+		case errors.Is(err, ErrAccountExists), errors.Is(err, ErrReservedName):
+			writeAPIError(w, http.StatusConflict, err)
+		case errors.Is(err, ErrWeakPassword), errors.Is(err, ErrInvalidUsername),
+			errors.Is(err, ErrNameSpaces), errors.Is(err, ErrPasswordTooLong):
+			writeAPIError(w, http.StatusBadRequest, err)
+		case err != nil:
+			log.Printf("⚠️ Failed to register %s: %v", creds.Username, err)
+			writeAPIError(w, http.StatusInternalServerError, errors.New("registration failed"))
+		default:
+			log.Printf("👤 Registered account %s", creds.Username)
+			w.WriteHeader(http.StatusCreated)
+		}
+	}
+}
+
+// handleLogin checks credentials and returns a session token
+func handleLogin(accounts *AccountStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		creds, ok := readCredentials(w, r)
+		if !ok {
+			return
+		}
+
+		token, expires, err := accounts.Login(creds.Username, creds.Password)
+		switch {
+		case errors.Is(err, ErrUnknownAccount):
+			writeAPIError(w, http.StatusNotFound, err)
+		case errors.Is(err, ErrBadCredentials):
+			log.Printf("🔒 Failed login for %s", creds.Username)
+			writeAPIError(w, http.StatusUnauthorized, err)
+		case err != nil:
+			log.Printf("⚠️ Failed to log in %s: %v", creds.Username, err)
+			writeAPIError(w, http.StatusInternalServerError, errors.New("login failed"))
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(models.Session{
+				Username:  creds.Username,
+				Token:     token,
+				ExpiresAt: expires,
+			})
+		}
+	}
+}
+
+// readCredentials decodes a POSTed credentials body
+func readCredentials(w http.ResponseWriter, r *http.Request) (models.Credentials, bool) {
+	var creds models.Credentials
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return creds, false
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&creds); err != nil {
+		writeAPIError(w, http.StatusBadRequest, errors.New("invalid request body"))
+		return creds, false
+	}
+	return creds, true
+}
+
+// writeAPIError sends a JSON error body
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(models.APIError{Error: err.Error()})
 }
 
 func showServerInfo(port string) {
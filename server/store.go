@@ -0,0 +1,169 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"terminal-chat/models"
+)
+
+// ErrMessageNotFound is returned by Find for unknown message IDs
+var ErrMessageNotFound = errors.New("message not found")
+
+// memoryStoreCap bounds how many messages the memory store keeps per room
+const memoryStoreCap = 1000
+
+// MessageStore persists room messages so they can be replayed later
+type MessageStore interface {
+	// Append records a message that was broadcast to its room
+	Append(msg *models.Message) error
+	// Recent returns up to limit of the latest messages in a room, oldest first
+	Recent(room string, limit int) ([]*models.Message, error)
+	// Range returns up to limit messages with since < Seq <= until, oldest
+	// first; until 0 means no upper bound
+	Range(room string, since, until uint64, limit int) ([]*models.Message, error)
+	// LastSeq returns the highest sequence number stored for a room
+	LastSeq(room string) (uint64, error)
+	// Find returns the message with the given ID in a room
+	Find(room, id string) (*models.Message, error)
+	// Update replaces the stored copy of an edited or deleted message
+	Update(msg *models.Message) error
+	// Close flushes and releases the backend
+	Close() error
+}
+
+// OpenStore opens the message store selected in the config
+func OpenStore(cfg *Config) (MessageStore, error) {
+	switch cfg.HistoryBackend {
+	case "memory":
+		return NewMemoryStore(), nil
+	case "jsonl":
+		if err := os.MkdirAll(cfg.DataDir, 0o755); err != nil {
+			return nil, err
+		}
+		return NewJSONLStore(filepath.Join(cfg.DataDir, "history.jsonl"))
+	case "bolt":
+		if err := os.MkdirAll(cfg.DataDir, 0o755); err != nil {
+			return nil, err
+		}
+		return NewBoltStore(filepath.Join(cfg.DataDir, "history.db"))
+	}
+	return nil, fmt.Errorf("unknown history backend %q", cfg.HistoryBackend)
+}
+
+// MemoryStore keeps recent messages in memory only
+type MemoryStore struct {
+	mu    sync.Mutex
+	rooms map[string][]*models.Message
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{rooms: make(map[string][]*models.Message)}
+}
+
+// Append records a message
+func (s *MemoryStore) Append(msg *models.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	copied := *msg
+	messages := append(s.rooms[msg.Room], &copied)
+	if len(messages) > memoryStoreCap {
+		messages = messages[len(messages)-memoryStoreCap:]
+	}
+	s.rooms[msg.Room] = messages
+	return nil
+}
+
+// Recent returns the latest messages in a room
+func (s *MemoryStore) Recent(room string, limit int) ([]*models.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	messages := s.rooms[room]
+	if len(messages) > limit {
+		messages = messages[len(messages)-limit:]
+	}
+	return copyMessages(messages), nil
+}
+
+// Range returns the retained messages of a room within a sequence range
+func (s *MemoryStore) Range(room string, since, until uint64, limit int) ([]*models.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []*models.Message
+	for _, msg := range s.rooms[room] {
+		if len(result) >= limit {
+			break
+		}
+		if inRange(msg.Seq, since, until) {
+			result = append(result, msg)
+		}
+	}
+	return copyMessages(result), nil
+}
+
+// LastSeq returns the sequence number of the newest retained message
+func (s *MemoryStore) LastSeq(room string) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	messages := s.rooms[room]
+	if len(messages) == 0 {
+		return 0, nil
+	}
+	return messages[len(messages)-1].Seq, nil
+}
+
+// Find looks a message up by ID among the retained ones
+func (s *MemoryStore) Find(room, id string) (*models.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, msg := range s.rooms[room] {
+		if msg.ID == id {
+			copied := *msg
+			return &copied, nil
+		}
+	}
+	return nil, ErrMessageNotFound
+}
+
+// Update replaces a retained message with the same ID
+func (s *MemoryStore) Update(msg *models.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, stored := range s.rooms[msg.Room] {
+		if stored.ID == msg.ID {
+			copied := *msg
+			s.rooms[msg.Room][i] = &copied
+			return nil
+		}
+	}
+	return ErrMessageNotFound
+}
+
+// Close is a no-op for the memory store
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// inRange reports whether since < seq <= until, where until 0 is unbounded
+func inRange(seq, since, until uint64) bool {
+	return seq > since && (until == 0 || seq <= until)
+}
+
+// copyMessages returns copies so callers can mark them without touching the store
+func copyMessages(messages []*models.Message) []*models.Message {
+	result := make([]*models.Message, 0, len(messages))
+	for _, msg := range messages {
+		copied := *msg
+		result = append(result, &copied)
+	}
+	return result
+}
@@ -0,0 +1,245 @@
+package server
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"terminal-chat/models"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// roomsBucket holds one nested bucket of messages per room
+	roomsBucket = []byte("rooms")
+	// idsBucket holds one nested bucket per room mapping message IDs to
+	// the keys of the messages in roomsBucket
+	idsBucket = []byte("ids")
+)
+
+// BoltStore keeps history in an embedded bbolt database, one bucket per
+// room, with an index of message IDs so lookups need not scan the room
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore opens (or creates) a bbolt history database
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(roomsBucket); err != nil {
+			return err
+		}
+		if tx.Bucket(idsBucket) != nil {
+			return nil
+		}
+		return indexIDs(tx)
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+// Append stores a message under its sequence number, or the bucket's next
+// sequence for messages that carry none
+func (s *BoltStore) Append(msg *models.Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.Bucket(roomsBucket).CreateBucketIfNotExists([]byte(msg.Room))
+		if err != nil {
+			return err
+		}
+		seq := msg.Seq
+		if seq == 0 {
+			if seq, err = bucket.NextSequence(); err != nil {
+				return err
+			}
+		} else if seq > bucket.Sequence() {
+			if err := bucket.SetSequence(seq); err != nil {
+				return err
+			}
+		}
+		if err := bucket.Put(seqKey(seq), data); err != nil {
+			return err
+		}
+		return putID(tx, msg.Room, msg.ID, seq)
+	})
+}
+
+// indexIDs builds the ID index of a database written before it existed
+func indexIDs(tx *bolt.Tx) error {
+	if _, err := tx.CreateBucket(idsBucket); err != nil {
+		return err
+	}
+	return tx.Bucket(roomsBucket).ForEachBucket(func(room []byte) error {
+		return tx.Bucket(roomsBucket).Bucket(room).ForEach(func(k, v []byte) error {
+			msg, err := decodeStored(k, v)
+			if err != nil {
+				return err
+			}
+			return putID(tx, string(room), msg.ID, binary.BigEndian.Uint64(k))
+		})
+	})
+}
+
+// putID records the key of a message in the room's ID index
+func putID(tx *bolt.Tx, room, id string, seq uint64) error {
+	if id == "" {
+		return nil
+	}
+	ids, err := tx.Bucket(idsBucket).CreateBucketIfNotExists([]byte(room))
+	if err != nil {
+		return err
+	}
+	return ids.Put([]byte(id), seqKey(seq))
+}
+
+// Recent walks the room bucket backwards from the newest key
+func (s *BoltStore) Recent(room string, limit int) ([]*models.Message, error) {
+	var result []*models.Message
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(roomsBucket).Bucket([]byte(room))
+		if bucket == nil {
+			return nil
+		}
+
+		cursor := bucket.Cursor()
+		for k, v := cursor.Last(); k != nil && len(result) < limit; k, v = cursor.Prev() {
+			msg, err := decodeStored(k, v)
+			if err != nil {
+				return err
+			}
+			result = append(result, msg)
+		}
+		return nil
+	})
+
+	// Oldest first
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result, err
+}
+
+// Range seeks to the first key after since and walks forwards
+func (s *BoltStore) Range(room string, since, until uint64, limit int) ([]*models.Message, error) {
+	var result []*models.Message
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(roomsBucket).Bucket([]byte(room))
+		if bucket == nil {
+			return nil
+		}
+
+		cursor := bucket.Cursor()
+		for k, v := cursor.Seek(seqKey(since + 1)); k != nil && len(result) < limit; k, v = cursor.Next() {
+			if until != 0 && binary.BigEndian.Uint64(k) > until {
+				break
+			}
+			msg, err := decodeStored(k, v)
+			if err != nil {
+				return err
+			}
+			result = append(result, msg)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// LastSeq reads the newest key of the room bucket
+func (s *BoltStore) LastSeq(room string) (uint64, error) {
+	var last uint64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(roomsBucket).Bucket([]byte(room))
+		if bucket == nil {
+			return nil
+		}
+		if k, _ := bucket.Cursor().Last(); k != nil {
+			last = binary.BigEndian.Uint64(k)
+		}
+		return nil
+	})
+	return last, err
+}
+
+// Find looks a message up by ID in the room's index
+func (s *BoltStore) Find(room, id string) (*models.Message, error) {
+	var found *models.Message
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(roomsBucket).Bucket([]byte(room))
+		ids := tx.Bucket(idsBucket).Bucket([]byte(room))
+		if bucket == nil || ids == nil || id == "" {
+			return nil
+		}
+		key := ids.Get([]byte(id))
+		if key == nil {
+			return nil
+		}
+		value := bucket.Get(key)
+		if value == nil {
+			return nil
+		}
+		msg, err := decodeStored(key, value)
+		found = msg
+		return err
+	})
+	if err == nil && found == nil {
+		err = ErrMessageNotFound
+	}
+	return found, err
+}
+
+// Update overwrites the message stored under its sequence number
+func (s *BoltStore) Update(msg *models.Message) error {
+	if msg.Seq == 0 {
+		return ErrMessageNotFound
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(roomsBucket).Bucket([]byte(msg.Room))
+		if bucket == nil || bucket.Get(seqKey(msg.Seq)) == nil {
+			return ErrMessageNotFound
+		}
+		return bucket.Put(seqKey(msg.Seq), data)
+	})
+}
+
+// Close closes the database
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// decodeStored parses a stored message; messages written before sequence
+// numbers existed take theirs from the key
+func decodeStored(key, value []byte) (*models.Message, error) {
+	var msg models.Message
+	if err := json.Unmarshal(value, &msg); err != nil {
+		return nil, err
+	}
+	if msg.Seq == 0 {
+		msg.Seq = binary.BigEndian.Uint64(key)
+	}
+	return &msg, nil
+}
+
+// seqKey encodes a sequence number so keys sort in insertion order
+func seqKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
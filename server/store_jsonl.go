@@ -0,0 +1,221 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"terminal-chat/models"
+)
+
+// JSONLStore appends every message as one JSON line to a file. An index
+// of where each room's messages sit in the file, built at open and kept up
+// on every append, lets reads go straight to the lines they need.
+type JSONLStore struct {
+	mu    sync.Mutex
+	file  *os.File
+	size  int64                 // Offset the next line is written at
+	rooms map[string]*jsonlRoom // Index of the file by room
+}
+
+// jsonlRoom indexes one room's lines, oldest first
+type jsonlRoom struct {
+	lines   []jsonlLine
+	byID    map[string]int // Position in lines of each message ID
+	lastSeq uint64
+}
+
+// jsonlLine is where the latest version of a message sits in the file
+type jsonlLine struct {
+	seq    uint64
+	offset int64
+	length int
+}
+
+// NewJSONLStore opens (or creates) an append-only history file and
+// indexes what it already holds
+func NewJSONLStore(path string) (*JSONLStore, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	s := &JSONLStore{file: file, rooms: make(map[string]*jsonlRoom)}
+	if err := s.buildIndex(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// buildIndex reads the file once to index every readable line. A torn last
+// line from an interrupted write is ended, so the next append starts clean.
+func (s *JSONLStore) buildIndex() error {
+	reader := bufio.NewReaderSize(s.file, 64*1024)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			s.index(line[:len(line)-1], offset)
+		} else if len(line) > 0 {
+			if _, err := s.file.Write([]byte{'\n'}); err != nil {
+				return err
+			}
+			line = append(line, '\n')
+		}
+		offset += int64(len(line))
+
+		if err == io.EOF {
+			s.size = offset
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// index records a line at offset; a later version of a message takes the
+// place of the first. Lines that do not parse are skipped.
+func (s *JSONLStore) index(data []byte, offset int64) {
+	var head struct {
+		ID   string `json:"id"`
+		Room string `json:"room"`
+		Seq  uint64 `json:"seq"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return
+	}
+
+	room := s.rooms[head.Room]
+	if room == nil {
+		room = &jsonlRoom{byID: make(map[string]int)}
+		s.rooms[head.Room] = room
+	}
+	line := jsonlLine{seq: head.Seq, offset: offset, length: len(data)}
+	room.lastSeq = max(room.lastSeq, head.Seq)
+	if i, seen := room.byID[head.ID]; seen && head.ID != "" {
+		room.lines[i] = line
+		return
+	}
+	room.byID[head.ID] = len(room.lines)
+	room.lines = append(room.lines, line)
+}
+
+// Append writes a message to the end of the file
+func (s *JSONLStore) Append(msg *models.Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n, err := s.file.Write(append(data, '\n'))
+	if err != nil {
+		// Whatever part made it stays in the file; count it so offsets hold
+		s.size += int64(n)
+		return err
+	}
+	s.index(data, s.size)
+	s.size += int64(n)
+	return nil
+}
+
+// Recent reads the last messages for the room
+func (s *JSONLStore) Recent(room string, limit int) ([]*models.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var lines []jsonlLine
+	if r := s.rooms[room]; r != nil {
+		lines = r.lines[max(0, len(r.lines)-limit):]
+	}
+	return s.read(lines)
+}
+
+// Range reads the room's messages within a sequence range
+func (s *JSONLStore) Range(room string, since, until uint64, limit int) ([]*models.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var lines []jsonlLine
+	if r := s.rooms[room]; r != nil {
+		for _, line := range r.lines {
+			if len(lines) >= limit {
+				break
+			}
+			if inRange(line.seq, since, until) {
+				lines = append(lines, line)
+			}
+		}
+	}
+	return s.read(lines)
+}
+
+// LastSeq returns the room's highest sequence number
+func (s *JSONLStore) LastSeq(room string) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r := s.rooms[room]; r != nil {
+		return r.lastSeq, nil
+	}
+	return 0, nil
+}
+
+// Find reads a message of the room by ID
+func (s *JSONLStore) Find(room, id string) (*models.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.rooms[room]
+	if r == nil || id == "" {
+		return nil, ErrMessageNotFound
+	}
+	i, ok := r.byID[id]
+	if !ok {
+		return nil, ErrMessageNotFound
+	}
+	messages, err := s.read(r.lines[i : i+1])
+	if err != nil {
+		return nil, err
+	}
+	return messages[0], nil
+}
+
+// Update appends the new version of a message, which the index puts in
+// the place of the first
+func (s *JSONLStore) Update(msg *models.Message) error {
+	return s.Append(msg)
+}
+
+// read decodes indexed lines from the file. The caller holds s.mu.
+func (s *JSONLStore) read(lines []jsonlLine) ([]*models.Message, error) {
+	result := make([]*models.Message, 0, len(lines))
+	for _, line := range lines {
+		data := make([]byte, line.length)
+		if _, err := s.file.ReadAt(data, line.offset); err != nil {
+			return result, err
+		}
+		var msg models.Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return result, err
+		}
+		result = append(result, &msg)
+	}
+	return result, nil
+}
+
+// Close syncs and closes the file
+func (s *JSONLStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.file.Sync(); err != nil {
+		s.file.Close()
+		return err
+	}
+	return s.file.Close()
+}
@@ -0,0 +1,195 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"terminal-chat/models"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// storeMessage is message seq of a room, with an ID unique across rooms
+func storeMessage(room string, seq uint64) *models.Message {
+	msg := models.NewMessage(models.MessageTypeChat, "alice", fmt.Sprintf("%s %d", room, seq), room)
+	msg.ID, msg.Seq = fmt.Sprintf("%s-%d", room, seq), seq
+	return msg
+}
+
+// contents lists the content of messages, for comparing results
+func contents(messages []*models.Message) []string {
+	result := make([]string, len(messages))
+	for i, msg := range messages {
+		result[i] = msg.Content
+	}
+	return result
+}
+
+func TestStores(t *testing.T) {
+	backends := map[string]func(t *testing.T) MessageStore{
+		"memory": func(t *testing.T) MessageStore { return NewMemoryStore() },
+		"jsonl": func(t *testing.T) MessageStore {
+			s, err := NewJSONLStore(filepath.Join(t.TempDir(), "history.jsonl"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return s
+		},
+		"bolt": func(t *testing.T) MessageStore {
+			s, err := NewBoltStore(filepath.Join(t.TempDir(), "history.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return s
+		},
+	}
+
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			s := open(t)
+			defer s.Close()
+			for seq := uint64(1); seq <= 10; seq++ {
+				for _, room := range []string{"a", "b"} {
+					if err := s.Append(storeMessage(room, seq)); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			recent, err := s.Recent("a", 3)
+			if got, want := fmt.Sprint(contents(recent)), "[a 8 a 9 a 10]"; err != nil || got != want {
+				t.Errorf("Recent = %s, %v; want %s", got, err, want)
+			}
+			between, err := s.Range("b", 4, 7, 10)
+			if got, want := fmt.Sprint(contents(between)), "[b 5 b 6 b 7]"; err != nil || got != want {
+				t.Errorf("Range = %s, %v; want %s", got, err, want)
+			}
+			capped, err := s.Range("b", 0, 0, 2)
+			if got, want := fmt.Sprint(contents(capped)), "[b 1 b 2]"; err != nil || got != want {
+				t.Errorf("capped Range = %s, %v; want %s", got, err, want)
+			}
+			if last, err := s.LastSeq("a"); err != nil || last != 10 {
+				t.Errorf("LastSeq = %d, %v; want 10", last, err)
+			}
+			if last, err := s.LastSeq("nowhere"); err != nil || last != 0 {
+				t.Errorf("LastSeq of an empty room = %d, %v", last, err)
+			}
+
+			edited := storeMessage("a", 4)
+			edited.Content, edited.Edited = "a 4 edited", true
+			if err := s.Update(edited); err != nil {
+				t.Fatal(err)
+			}
+			found, err := s.Find("a", "a-4")
+			if err != nil || found.Content != "a 4 edited" {
+				t.Errorf("Find after Update = %+v, %v", found, err)
+			}
+			around, _ := s.Range("a", 2, 5, 10)
+			if got, want := fmt.Sprint(contents(around)), "[a 3 a 4 edited a 5]"; got != want {
+				t.Errorf("an edit moved its message: Range = %s, want %s", got, want)
+			}
+			if _, err := s.Find("b", "a-4"); err != ErrMessageNotFound {
+				t.Errorf("Find in the wrong room = %v", err)
+			}
+
+			// Callers may mark what they get without changing the store
+			recent[0].Content = "changed"
+			if again, _ := s.Recent("a", 3); again[0].Content != "a 8" {
+				t.Error("a returned message shares memory with the store")
+			}
+		})
+	}
+}
+
+func TestJSONLStoreReopens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	s, err := NewJSONLStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for seq := uint64(1); seq <= 3; seq++ {
+		s.Append(storeMessage("a", seq))
+	}
+	edited := storeMessage("a", 2)
+	edited.Content = "a 2 edited"
+	s.Update(edited)
+	s.Close()
+
+	// A write cut short by a crash leaves half a line behind
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"type":"chat","room":"a","id":"a-4","se`)
+	file.Close()
+
+	s, err = NewJSONLStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.Append(storeMessage("a", 4)); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := s.Recent("a", 10)
+	if got, want := fmt.Sprint(contents(all)), "[a 1 a 2 edited a 3 a 4]"; err != nil || got != want {
+		t.Errorf("after reopening, Recent = %s, %v; want %s", got, err, want)
+	}
+	if last, _ := s.LastSeq("a"); last != 4 {
+		t.Errorf("after reopening, LastSeq = %d, want 4", last)
+	}
+}
+
+func TestBoltStoreIndexesOlderDatabases(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+
+	// A database from before the ID index: messages only
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		room, err := tx.CreateBucketIfNotExists(roomsBucket)
+		if err != nil {
+			return err
+		}
+		if room, err = room.CreateBucket([]byte("a")); err != nil {
+			return err
+		}
+		for seq := uint64(1); seq <= 3; seq++ {
+			data, _ := json.Marshal(storeMessage("a", seq))
+			if err := room.Put(seqKey(seq), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewBoltStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found, err := s.Find("a", "a-2"); err != nil || found.Content != "a 2" {
+		t.Errorf("Find of an older message = %+v, %v", found, err)
+	}
+	s.Append(storeMessage("a", 4))
+	s.Close()
+
+	s, err = NewBoltStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for _, id := range []string{"a-1", "a-4"} {
+		if _, err := s.Find("a", id); err != nil {
+			t.Errorf("after reopening, Find(%s) = %v", id, err)
+		}
+	}
+}
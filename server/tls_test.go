@@ -0,0 +1,49 @@
+package server
+
+import (
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSelfSignedCertificate(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.DataDir = t.TempDir()
+	if tlsConfig, err := loadTLSConfig(cfg); err != nil || tlsConfig != nil {
+		t.Fatalf("without TLS settings got %v, %v; want plain ws://", tlsConfig, err)
+	}
+
+	cfg.TLSSelfSigned = true
+	first, err := loadTLSConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(first.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := leaf.VerifyHostname("localhost"); err != nil {
+		t.Error(err)
+	}
+	if err := leaf.VerifyHostname("127.0.0.1"); err != nil {
+		t.Error(err)
+	}
+	if info, err := os.Stat(filepath.Join(cfg.DataDir, "selfsigned-key.pem")); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("key file: %v, %v", info, err)
+	}
+
+	// A restart keeps the certificate clients have pinned
+	second, err := loadTLSConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if certFingerprint(first) != certFingerprint(second) {
+		t.Error("the certificate was regenerated on restart")
+	}
+
+	cfg.TLSCert = filepath.Join(cfg.DataDir, "selfsigned-cert.pem")
+	if _, err := loadTLSConfig(cfg); err == nil {
+		t.Error("a certificate without a key was accepted")
+	}
+}
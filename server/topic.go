@@ -0,0 +1,94 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"terminal-chat/models"
+	"unicode/utf8"
+)
+
+// Longest topic and description a room can have, in characters
+const (
+	maxTopicLength       = 100
+	maxDescriptionLength = 300
+)
+
+// roomInfo describes a room for its header and the room directory
+func (h *Hub) roomInfo(room string) models.Room {
+	info := models.Room{Name: room, Members: len(h.rooms[room])}
+	if state := h.roomStates.Find(room); state != nil {
+		info.Topic = state.Topic
+		info.TopicBy = state.TopicBy
+		info.Description = state.Description
+		info.CreatedBy = state.Owner
+		info.CreatedAt = state.CreatedAt
+	}
+	return info
+}
+
+// sendRoomInfo tells a client joining a room what the room is about. It is
+// sent even when nothing is set, so a stale topic never outlives a rejoin.
+func (h *Hub) sendRoomInfo(client *Client, room string) {
+	info := h.roomInfo(room)
+	msg := models.NewMessage(models.MessageTypeTopic, "system", "", room)
+	msg.RoomInfo = &info
+	h.sendToClient(client, msg)
+}
+
+// setTopic changes a room's topic or description, which only its owner and
+// moderators may do, and shows everyone in the room the result
+func (h *Hub) setTopic(client *Client, msg *models.Message) {
+	room, ok := h.resolveRoom(client, msg.Room)
+	if !ok {
+		h.rejectMessage(client, msg, models.ErrCodeWrongRoom,
+			fmt.Sprintf("You are not a member of room '%s'", msg.Room))
+		return
+	}
+
+	what, limit := "topic", maxTopicLength
+	if msg.Action == models.RoomFieldDescription {
+		what, limit = "description", maxDescriptionLength
+	} else if msg.Action != models.RoomFieldTopic {
+		h.rejectMessage(client, msg, models.ErrCodeBadMessage, fmt.Sprintf("Unknown room field %q", msg.Action))
+		return
+	}
+
+	if h.role(room, client) == models.RoleMember {
+		h.rejectMessage(client, msg, models.ErrCodeNotAllowed,
+			fmt.Sprintf("Only the room's moderators can change its %s", what))
+		return
+	}
+	if !h.checkPosting(client, msg, room) {
+		return
+	}
+
+	text := strings.Join(strings.Fields(msg.Content), " ")
+	if utf8.RuneCountInString(text) > limit {
+		h.rejectMessage(client, msg, models.ErrCodeBadMessage,
+			fmt.Sprintf("The %s can be at most %d characters", what, limit))
+		return
+	}
+
+	state := h.roomStates.Get(room)
+	var notice string
+	switch {
+	case msg.Action == models.RoomFieldDescription:
+		state.Description = text
+		notice = fmt.Sprintf("📝 %s updated the description of #%s", client.Username, room)
+	case text == "":
+		state.Topic, state.TopicBy = "", ""
+		notice = fmt.Sprintf("📌 %s cleared the topic of #%s", client.Username, room)
+	default:
+		state.Topic, state.TopicBy = text, client.Username
+		notice = fmt.Sprintf("📌 %s set the topic of #%s: %s", client.Username, room, text)
+	}
+
+	log.Print(notice)
+	h.saveRoomStates()
+
+	info := h.roomInfo(room)
+	update := models.NewMessage(models.MessageTypeTopic, client.Username, notice, room)
+	update.RoomInfo = &info
+	h.broadcastToRoom(update, room)
+}
@@ -0,0 +1,188 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"terminal-chat/models"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// maxDownloads is how many file downloads a connection may queue
+const maxDownloads = 4
+
+// maxFrameSize is the largest frame a client may send: a file chunk with
+// its ID in front. Text frames are still held to maxMessageSize.
+const maxFrameSize = models.FileIDSize + models.FileChunkSize
+
+// ready is always ready to receive from, for select cases that should fire
+// whenever nothing else is waiting
+var ready = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+// offerFile accepts an upload into a room the sender may post in and
+// replies with the ID its chunks must carry
+func (h *Hub) offerFile(client *Client, msg *models.Message) {
+	room, ok := h.resolveRoom(client, msg.Room)
+	if !ok {
+		h.rejectMessage(client, msg, models.ErrCodeWrongRoom,
+			fmt.Sprintf("You are not a member of room '%s'", msg.Room))
+		return
+	}
+	msg.Room = room
+	if !h.checkPosting(client, msg, room) {
+		return
+	}
+
+	if msg.File == nil || !validDigest(msg.File.SHA256) || msg.File.Size <= 0 {
+		h.rejectMessage(client, msg, models.ErrCodeBadMessage, "File offers need a size and a SHA-256 checksum")
+		return
+	}
+	name := cleanFileName(msg.File.Name)
+	if name == "" {
+		h.rejectMessage(client, msg, models.ErrCodeBadMessage, "That file name cannot be shared")
+		return
+	}
+	if msg.File.Size > int64(h.cfg.MaxFileSize) {
+		h.rejectMessage(client, msg, models.ErrCodeFileRefused,
+			fmt.Sprintf("Files can be at most %s", h.cfg.MaxFileSize))
+		return
+	}
+
+	file := models.File{Name: name, Size: msg.File.Size, SHA256: msg.File.SHA256}
+	id, err := h.files.Begin(file, room, msg.ClientID, client)
+	if err != nil {
+		if !errors.Is(err, ErrFileStoreFull) {
+			log.Printf("⚠️ Failed to start an upload for %s: %v", client.Username, err)
+			err = errors.New("the server could not store it")
+		}
+		h.rejectMessage(client, msg, models.ErrCodeFileRefused, fmt.Sprintf("Cannot send %s: %v", name, err))
+		return
+	}
+
+	log.Printf("📤 %s is uploading %s (%d bytes) to room '%s' as %s", client.Username, name, file.Size, room, id)
+	file.ID = id
+	reply := models.NewMessage(models.MessageTypeFileOffer, "system", "", room)
+	reply.ClientID = msg.ClientID
+	reply.File = &file
+	h.sendToClient(client, reply)
+}
+
+// finishUpload announces a complete upload to its room like any other
+// message, or tells the uploader why it failed
+func (h *Hub) finishUpload(client *Client, file *storedFile, err error) {
+	msg := models.NewMessage(models.MessageTypeFile, client.Username, file.Name, file.room)
+	msg.ClientID = file.clientID
+	if err != nil {
+		log.Printf("⚠️ Upload of %s by %s failed: %v", file.ID, client.Username, err)
+		h.rejectMessage(client, msg, models.ErrCodeFileRefused, fmt.Sprintf("Sending %s failed: %v", file.Name, err))
+		return
+	}
+
+	shared := file.File
+	msg.File = &shared
+	h.broadcastMessage(client, msg)
+}
+
+// requestFile queues a file for download by a member of the room it was
+// shared in; the client's writePump streams it
+func (h *Hub) requestFile(client *Client, msg *models.Message) {
+	file := h.files.Find(msg.Target)
+	if file == nil {
+		h.rejectMessage(client, msg, models.ErrCodeNotFound, fmt.Sprintf("File %s: %v", msg.Target, ErrFileNotFound))
+		return
+	}
+	if !client.rooms[file.room] {
+		h.rejectMessage(client, msg, models.ErrCodeNotAllowed,
+			fmt.Sprintf("Join room '%s' to download %s", file.room, file.Name))
+		return
+	}
+
+	select {
+	case client.downloads <- file.ID:
+		log.Printf("📥 %s is downloading %s (%s)", client.Username, file.Name, file.ID)
+	default:
+		h.rejectMessage(client, msg, models.ErrCodeRateLimited,
+			fmt.Sprintf("Wait for your other downloads to finish before fetching %s", file.Name))
+	}
+}
+
+// receiveChunk stores a binary frame of one of the connection's uploads and
+// hands the file to the hub once it is complete. It reports whether the
+// frame belonged to an upload.
+func (c *Client) receiveChunk(frame []byte) bool {
+	if len(frame) < models.FileIDSize {
+		return false
+	}
+	id, chunk := string(frame[:models.FileIDSize]), frame[models.FileIDSize:]
+	file, err := c.hub.files.Write(c, id, chunk)
+	if errors.Is(err, ErrFileNotFound) {
+		return false
+	}
+	if file != nil {
+		select {
+		case c.hub.broadcast <- &envelope{client: c, upload: file, uploadErr: err}:
+		case <-c.hub.done:
+		}
+	}
+	return true
+}
+
+// download is a file a writePump is streaming to its client
+type download struct {
+	id  string
+	in  *os.File
+	buf []byte
+}
+
+// startDownload opens a queued file and sends the frame that announces
+// its chunks. It returns nil when there is nothing to stream.
+func (c *Client) startDownload(id string) (*download, error) {
+	in, file, err := c.hub.files.Open(id)
+	if err != nil {
+		errMsg := models.NewErrorMessage(models.ErrCodeNotFound, fmt.Sprintf("File %s: %v", id, err), "")
+		errMsg.Target = id
+		return nil, c.conn.WriteMessage(websocket.TextMessage, errMsg.ToJSON())
+	}
+
+	header := models.NewMessage(models.MessageTypeFileRequest, "system", "", file.room)
+	shared := file.File
+	header.File = &shared
+	if err := c.conn.WriteMessage(websocket.TextMessage, header.ToJSON()); err != nil {
+		in.Close()
+		return nil, err
+	}
+
+	d := &download{id: id, in: in, buf: make([]byte, maxFrameSize)}
+	copy(d.buf, id)
+	return d, nil
+}
+
+// sendChunk writes the next chunk of a download and reports whether any
+// is left. The download is closed once it is done or fails.
+func (d *download) sendChunk(conn *websocket.Conn) (bool, error) {
+	n, err := io.ReadFull(d.in, d.buf[models.FileIDSize:])
+	if n > 0 {
+		conn.SetWriteDeadline(time.Now().Add(writeWait))
+		if werr := conn.WriteMessage(websocket.BinaryMessage, d.buf[:models.FileIDSize+n]); werr != nil {
+			d.in.Close()
+			return false, werr
+		}
+	}
+	if err == nil {
+		return true, nil
+	}
+	d.in.Close()
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return false, nil
+	}
+	log.Printf("⚠️ Failed to read file %s for download: %v", d.id, err)
+	return false, nil
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"terminal-chat/models"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// offer offers a file to room and returns the server's reply
+func (c *testConn) offer(room, name string, data []byte, sum string) models.Message {
+	c.t.Helper()
+	msg := models.NewMessage(models.MessageTypeFileOffer, "", "", room)
+	msg.ClientID = "offer-" + name
+	msg.File = &models.File{Name: name, Size: int64(len(data)), SHA256: sum}
+	c.send(msg)
+	for {
+		select {
+		case reply := <-c.frames:
+			if reply.ClientID == msg.ClientID {
+				return reply
+			}
+		case err := <-c.closed:
+			c.t.Fatalf("connection closed waiting for the offer reply: %v", err)
+		}
+	}
+}
+
+// upload sends data as the chunks of file id
+func (c *testConn) upload(id string, data []byte) {
+	c.t.Helper()
+	for len(data) > 0 {
+		size := min(len(data), models.FileChunkSize)
+		frame := append([]byte(id), data[:size]...)
+		if err := c.conn.WriteMessage(websocket.BinaryMessage, frame); err != nil {
+			c.t.Fatal(err)
+		}
+		data = data[size:]
+	}
+}
+
+// digest is the hex SHA-256 of data
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestFileTransfer(t *testing.T) {
+	srv := newTestServer(t, DefaultConfig())
+	alice := srv.dial(t, "username=alice&room=r")
+	alice.joined("r")
+	bob := srv.dial(t, "username=bob&room=r")
+	bob.joined("r")
+
+	data := bytes.Repeat([]byte("0123456789abcdef"), models.FileChunkSize/8+3)
+	reply := alice.offer("r", "notes.txt", data, digest(data))
+	if reply.Type != models.MessageTypeFileOffer || reply.File == nil || len(reply.File.ID) != models.FileIDSize {
+		t.Fatalf("the offer was not accepted: %+v", reply)
+	}
+	alice.upload(reply.File.ID, data)
+
+	shared := bob.next(models.MessageTypeFile)
+	if shared.File == nil || shared.File.ID != reply.File.ID || shared.File.SHA256 != digest(data) {
+		t.Fatalf("the room was told of %+v", shared.File)
+	}
+
+	request := models.NewMessage(models.MessageTypeFileRequest, "", "", "")
+	request.Target = shared.File.ID
+	bob.send(request)
+	if header := bob.next(models.MessageTypeFileRequest); header.File == nil || header.File.Size != int64(len(data)) {
+		t.Fatalf("the download started with %+v", header.File)
+	}
+	var got []byte
+	for len(got) < len(data) {
+		frame := <-bob.binary
+		if id := string(frame[:models.FileIDSize]); id != shared.File.ID {
+			t.Fatalf("a chunk of %s arrived during %s", id, shared.File.ID)
+		}
+		got = append(got, frame[models.FileIDSize:]...)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("the downloaded file differs from the upload")
+	}
+}
+
+func TestUploadChecksumMismatch(t *testing.T) {
+	srv := newTestServer(t, DefaultConfig())
+	alice := srv.dial(t, "username=alice&room=r")
+	alice.joined("r")
+
+	data := []byte("the real content")
+	reply := alice.offer("r", "notes.txt", data, digest([]byte("something else")))
+	if reply.File == nil {
+		t.Fatalf("the offer was not accepted: %+v", reply)
+	}
+	alice.upload(reply.File.ID, data)
+
+	refused := alice.next(models.MessageTypeError)
+	if refused.Code != models.ErrCodeFileRefused || refused.ClientID != "offer-notes.txt" {
+		t.Fatalf("a corrupted upload got %+v", refused)
+	}
+	if srv.hub.files.Find(reply.File.ID) != nil {
+		t.Error("the corrupted upload was kept")
+	}
+}
+
+func TestOfferNeedsChecksum(t *testing.T) {
+	srv := newTestServer(t, DefaultConfig())
+	alice := srv.dial(t, "username=alice&room=r")
+	alice.joined("r")
+
+	if reply := alice.offer("r", "notes.txt", []byte("data"), "not a digest"); reply.Code != models.ErrCodeBadMessage {
+		t.Errorf("an offer without a checksum got %+v", reply)
+	}
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"terminal-chat/models"
+	"time"
+)
+
+// typingTimeout is how long a typing signal lasts unless it is refreshed.
+// Clients resend it every few seconds while the user keeps typing.
+const typingTimeout = 8 * time.Second
+
+// setTyping records that a user started or stopped typing in a room and
+// tells the room when that changes. Signals for rooms the client is not
+// in are dropped: they are not worth an error.
+func (h *Hub) setTyping(client *Client, msg *models.Message) {
+	room, ok := h.resolveRoom(client, msg.Room)
+	if !ok {
+		return
+	}
+	if msg.Type == models.MessageTypeTypingStop {
+		h.stopTyping(client.Username, room)
+		return
+	}
+
+	typers := h.typing[room]
+	if typers == nil {
+		typers = make(map[string]time.Time)
+		h.typing[room] = typers
+	}
+	_, already := typers[client.Username]
+	typers[client.Username] = time.Now().Add(typingTimeout)
+	if !already {
+		h.relayTyping(models.MessageTypeTypingStart, client.Username, room)
+	}
+}
+
+// stopTyping clears a user's typing signal in a room, if any
+func (h *Hub) stopTyping(username, room string) {
+	typers := h.typing[room]
+	if _, ok := typers[username]; !ok {
+		return
+	}
+	delete(typers, username)
+	if len(typers) == 0 {
+		delete(h.typing, room)
+	}
+	h.relayTyping(models.MessageTypeTypingStop, username, room)
+}
+
+// expireTyping stops the signals that were not refreshed in time, which
+// covers clients that went quiet without saying so
+func (h *Hub) expireTyping(now time.Time) {
+	for room, typers := range h.typing {
+		for username, expiry := range typers {
+			if now.After(expiry) {
+				h.stopTyping(username, room)
+			}
+		}
+	}
+}
+
+// relayTyping tells a room that a user started or stopped typing. Typing
+// signals are live only: they are neither stored nor numbered.
+func (h *Hub) relayTyping(typ models.MessageType, username, room string) {
+	msg := models.NewMessage(typ, username, "", room)
+	h.broadcastToRoom(msg, room)
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"terminal-chat/models"
+	"testing"
+	"time"
+)
+
+// typing tells room that the connection started or stopped typing
+func (c *testConn) typing(typ models.MessageType, room string) {
+	c.t.Helper()
+	c.send(models.NewMessage(typ, "", "", room))
+}
+
+func TestTypingSignals(t *testing.T) {
+	srv := newTestServer(t, DefaultConfig())
+	alice := srv.dial(t, "username=alice&room=r")
+	alice.joined("r")
+	bob := srv.dial(t, "username=bob&room=r")
+	bob.joined("r")
+
+	alice.typing(models.MessageTypeTypingStart, "r")
+	if got := bob.next(models.MessageTypeTypingStart); got.Username != "alice" || got.Room != "r" {
+		t.Fatalf("got %s typing in %s", got.Username, got.Room)
+	}
+
+	// Refreshing a signal is not news
+	alice.typing(models.MessageTypeTypingStart, "r")
+	bob.quiet(models.MessageTypeTypingStart, 100*time.Millisecond)
+
+	alice.typing(models.MessageTypeTypingStop, "r")
+	if got := bob.next(models.MessageTypeTypingStop); got.Username != "alice" {
+		t.Fatalf("got %s stopped typing", got.Username)
+	}
+
+	// Leaving stops the signal for whoever stays
+	alice.typing(models.MessageTypeTypingStart, "r")
+	bob.next(models.MessageTypeTypingStart)
+	alice.conn.Close()
+	if got := bob.next(models.MessageTypeTypingStop); got.Username != "alice" {
+		t.Fatalf("got %s stopped typing", got.Username)
+	}
+}
+
+func TestTypingExpires(t *testing.T) {
+	h := newTestHub(t, DefaultConfig(), t.TempDir())
+	alice, bob := testClient(h, "alice"), testClient(h, "bob")
+	h.rooms["r"] = map[*Client]bool{alice: true, bob: true}
+	alice.rooms["r"], bob.rooms["r"] = true, true
+
+	h.setTyping(alice, models.NewMessage(models.MessageTypeTypingStart, "", "", "r"))
+	h.expireTyping(time.Now().Add(typingTimeout / 2))
+	if _, typing := h.typing["r"]["alice"]; !typing {
+		t.Fatal("the signal expired early")
+	}
+	h.expireTyping(time.Now().Add(typingTimeout + time.Second))
+	if len(h.typing) != 0 {
+		t.Fatalf("still typing: %v", h.typing)
+	}
+	if got := len(bob.send); got != 2 {
+		t.Errorf("bob was sent %d signals, want a start and a stop", got)
+	}
+}
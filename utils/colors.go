@@ -18,6 +18,7 @@ var (
 	ColorCyan    = color.New(color.FgCyan).SprintFunc()
 	ColorWhite   = color.New(color.FgWhite).SprintFunc()
 	ColorBold    = color.New(color.Bold).SprintFunc()
+	ColorFaint   = color.New(color.Faint).SprintFunc()
 )
 
 // Color combinations
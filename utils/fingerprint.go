@@ -0,0 +1,18 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+)
+
+// CertFingerprint formats the SHA-256 digest of a DER certificate as
+// colon-separated hex, the form shown to users when pinning a server
+func CertFingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}